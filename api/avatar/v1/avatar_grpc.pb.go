@@ -0,0 +1,232 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/avatar/v1/avatar.proto
+
+package avatarv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AvatarService_GetAvatar_FullMethodName           = "/avatar.v1.AvatarService/GetAvatar"
+	AvatarService_ResolveAvatar_FullMethodName       = "/avatar.v1.AvatarService/ResolveAvatar"
+	AvatarService_BatchGetAvatars_FullMethodName     = "/avatar.v1.AvatarService/BatchGetAvatars"
+	AvatarService_BatchResolveAvatars_FullMethodName = "/avatar.v1.AvatarService/BatchResolveAvatars"
+)
+
+// AvatarServiceClient is the client API for AvatarService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AvatarServiceClient interface {
+	// GetAvatar returns the encoded avatar and its metadata, NOT_FOUND when the hash has none.
+	GetAvatar(ctx context.Context, in *GetAvatarRequest, opts ...grpc.CallOption) (*GetAvatarResponse, error)
+	// ResolveAvatar returns the metadata of the avatar only, NOT_FOUND when the hash has none.
+	ResolveAvatar(ctx context.Context, in *ResolveAvatarRequest, opts ...grpc.CallOption) (*ResolveAvatarResponse, error)
+	// BatchGetAvatars returns the avatars of many hashes in the order of the request,
+	// hashes without an avatar have `found` unset, hashes which failed have `error` set.
+	BatchGetAvatars(ctx context.Context, in *BatchGetAvatarsRequest, opts ...grpc.CallOption) (*BatchGetAvatarsResponse, error)
+	// BatchResolveAvatars returns the metadata of many hashes in the order of the request,
+	// hashes which failed have `error` set.
+	BatchResolveAvatars(ctx context.Context, in *BatchResolveAvatarsRequest, opts ...grpc.CallOption) (*BatchResolveAvatarsResponse, error)
+}
+
+type avatarServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAvatarServiceClient(cc grpc.ClientConnInterface) AvatarServiceClient {
+	return &avatarServiceClient{cc}
+}
+
+func (c *avatarServiceClient) GetAvatar(ctx context.Context, in *GetAvatarRequest, opts ...grpc.CallOption) (*GetAvatarResponse, error) {
+	out := new(GetAvatarResponse)
+	err := c.cc.Invoke(ctx, AvatarService_GetAvatar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *avatarServiceClient) ResolveAvatar(ctx context.Context, in *ResolveAvatarRequest, opts ...grpc.CallOption) (*ResolveAvatarResponse, error) {
+	out := new(ResolveAvatarResponse)
+	err := c.cc.Invoke(ctx, AvatarService_ResolveAvatar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *avatarServiceClient) BatchGetAvatars(ctx context.Context, in *BatchGetAvatarsRequest, opts ...grpc.CallOption) (*BatchGetAvatarsResponse, error) {
+	out := new(BatchGetAvatarsResponse)
+	err := c.cc.Invoke(ctx, AvatarService_BatchGetAvatars_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *avatarServiceClient) BatchResolveAvatars(ctx context.Context, in *BatchResolveAvatarsRequest, opts ...grpc.CallOption) (*BatchResolveAvatarsResponse, error) {
+	out := new(BatchResolveAvatarsResponse)
+	err := c.cc.Invoke(ctx, AvatarService_BatchResolveAvatars_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AvatarServiceServer is the server API for AvatarService service.
+// All implementations must embed UnimplementedAvatarServiceServer
+// for forward compatibility
+type AvatarServiceServer interface {
+	// GetAvatar returns the encoded avatar and its metadata, NOT_FOUND when the hash has none.
+	GetAvatar(context.Context, *GetAvatarRequest) (*GetAvatarResponse, error)
+	// ResolveAvatar returns the metadata of the avatar only, NOT_FOUND when the hash has none.
+	ResolveAvatar(context.Context, *ResolveAvatarRequest) (*ResolveAvatarResponse, error)
+	// BatchGetAvatars returns the avatars of many hashes in the order of the request,
+	// hashes without an avatar have `found` unset, hashes which failed have `error` set.
+	BatchGetAvatars(context.Context, *BatchGetAvatarsRequest) (*BatchGetAvatarsResponse, error)
+	// BatchResolveAvatars returns the metadata of many hashes in the order of the request,
+	// hashes which failed have `error` set.
+	BatchResolveAvatars(context.Context, *BatchResolveAvatarsRequest) (*BatchResolveAvatarsResponse, error)
+	mustEmbedUnimplementedAvatarServiceServer()
+}
+
+// UnimplementedAvatarServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAvatarServiceServer struct {
+}
+
+func (UnimplementedAvatarServiceServer) GetAvatar(context.Context, *GetAvatarRequest) (*GetAvatarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAvatar not implemented")
+}
+func (UnimplementedAvatarServiceServer) ResolveAvatar(context.Context, *ResolveAvatarRequest) (*ResolveAvatarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveAvatar not implemented")
+}
+func (UnimplementedAvatarServiceServer) BatchGetAvatars(context.Context, *BatchGetAvatarsRequest) (*BatchGetAvatarsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetAvatars not implemented")
+}
+func (UnimplementedAvatarServiceServer) BatchResolveAvatars(context.Context, *BatchResolveAvatarsRequest) (*BatchResolveAvatarsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchResolveAvatars not implemented")
+}
+func (UnimplementedAvatarServiceServer) mustEmbedUnimplementedAvatarServiceServer() {}
+
+// UnsafeAvatarServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AvatarServiceServer will
+// result in compilation errors.
+type UnsafeAvatarServiceServer interface {
+	mustEmbedUnimplementedAvatarServiceServer()
+}
+
+func RegisterAvatarServiceServer(s grpc.ServiceRegistrar, srv AvatarServiceServer) {
+	s.RegisterService(&AvatarService_ServiceDesc, srv)
+}
+
+func _AvatarService_GetAvatar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAvatarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AvatarServiceServer).GetAvatar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AvatarService_GetAvatar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AvatarServiceServer).GetAvatar(ctx, req.(*GetAvatarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AvatarService_ResolveAvatar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveAvatarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AvatarServiceServer).ResolveAvatar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AvatarService_ResolveAvatar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AvatarServiceServer).ResolveAvatar(ctx, req.(*ResolveAvatarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AvatarService_BatchGetAvatars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetAvatarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AvatarServiceServer).BatchGetAvatars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AvatarService_BatchGetAvatars_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AvatarServiceServer).BatchGetAvatars(ctx, req.(*BatchGetAvatarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AvatarService_BatchResolveAvatars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchResolveAvatarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AvatarServiceServer).BatchResolveAvatars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AvatarService_BatchResolveAvatars_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AvatarServiceServer).BatchResolveAvatars(ctx, req.(*BatchResolveAvatarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AvatarService_ServiceDesc is the grpc.ServiceDesc for AvatarService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AvatarService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "avatar.v1.AvatarService",
+	HandlerType: (*AvatarServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAvatar",
+			Handler:    _AvatarService_GetAvatar_Handler,
+		},
+		{
+			MethodName: "ResolveAvatar",
+			Handler:    _AvatarService_ResolveAvatar_Handler,
+		},
+		{
+			MethodName: "BatchGetAvatars",
+			Handler:    _AvatarService_BatchGetAvatars_Handler,
+		},
+		{
+			MethodName: "BatchResolveAvatars",
+			Handler:    _AvatarService_BatchResolveAvatars_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/avatar/v1/avatar.proto",
+}
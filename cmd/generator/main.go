@@ -11,9 +11,9 @@ import (
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 
-	"github.com/AH-dark/gravatar-with-qq-avatar/database/dal"
 	"github.com/AH-dark/gravatar-with-qq-avatar/entry"
 	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/cryptor"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
 )
 
 var ctx = context.Background()
@@ -29,16 +29,24 @@ func init() {
 	flag.Parse()
 }
 
-func generate(ctx context.Context, md5QQMappingRepo dal.MD5QQMappingRepo) error {
+// batchSize is the number of mappings written, and then invalidated, at once.
+const batchSize = 1000
+
+func generate(ctx context.Context, avatarService avatar.Service) error {
 	svr := md5simd.NewServer()
 	defer svr.Close()
 
+	batch := make([]avatar.Mapping, 0, batchSize)
 	for i := from; i <= to; i++ {
 		md5Hash := cryptor.Md5WithServer(svr, bytestring.StringToBytes(fmt.Sprintf("%d@qq.com", i)))
+		batch = append(batch, avatar.Mapping{Hash: bytestring.BytesToString(md5Hash), QQ: i})
 
-		if err := md5QQMappingRepo.InsertMapping(ctx, i, bytestring.BytesToString(md5Hash)); err != nil {
-			otelzap.L().Ctx(ctx).Error("insert qq avatar failed", zap.Error(err))
-			return err
+		if len(batch) == batchSize || i == to {
+			if err := avatarService.InsertMappings(ctx, batch...); err != nil {
+				otelzap.L().Ctx(ctx).Error("insert qq avatar failed", zap.Error(err))
+				return err
+			}
+			batch = batch[:0]
 		}
 
 		if i%10000 == 0 {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 	"github.com/AH-dark/gravatar-with-qq-avatar/entry"
 	"github.com/AH-dark/gravatar-with-qq-avatar/server"
 	"go.uber.org/fx"
@@ -18,6 +19,7 @@ func init() {
 		fx.Supply(fx.Annotate("main", fx.ResultTags(`name:"serviceName"`))),
 		entry.AppEntries(),
 		server.Module(),
+		fx.StopTimeout(config.StopTimeout),
 	)
 }
 
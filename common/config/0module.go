@@ -5,5 +5,7 @@ import "go.uber.org/fx"
 func Module() fx.Option {
 	return fx.Module("internal.config",
 		fx.Provide(NewViper),
+		fx.Provide(NewConfig),
+		fx.Provide(NewReloader),
 	)
 }
@@ -0,0 +1,496 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// Config is the whole configuration, decoded once at startup from the defaults, the config file, the remote provider
+// and the environment.
+// Modules applying changes live get the new values through the Reloader, this is the config the process started with.
+type Config struct {
+	Name       string `mapstructure:"name"`
+	Namespace  string `mapstructure:"namespace"`
+	Version    string `mapstructure:"version"`
+	InstanceID string `mapstructure:"instance_id"`
+	Debug      bool   `mapstructure:"debug"`
+
+	Config        SourceConfig        `mapstructure:"config"`
+	Logging       LoggingConfig       `mapstructure:"logging"`
+	Server        ServerConfig        `mapstructure:"server"`
+	GRPC          GRPCConfig          `mapstructure:"grpc"`
+	Admin         AdminConfig         `mapstructure:"admin"`
+	Observability ObservabilityConfig `mapstructure:"observability"`
+	Cassandra     CassandraConfig     `mapstructure:"cassandra"`
+	Redis         RedisConfig         `mapstructure:"redis"`
+	Cache         CacheConfig         `mapstructure:"cache"`
+	Images        ImagesConfig        `mapstructure:"images"`
+	CDN           CDNConfig           `mapstructure:"cdn"`
+	Profiles      ProfilesConfig      `mapstructure:"profiles"`
+	Upstreams     UpstreamsConfig     `mapstructure:"upstreams"`
+}
+
+type SourceConfig struct {
+	Watch  bool         `mapstructure:"watch"`
+	Remote RemoteConfig `mapstructure:"remote"`
+}
+
+type RemoteConfig struct {
+	Provider     string        `mapstructure:"provider"`
+	Endpoint     string        `mapstructure:"endpoint"`
+	Path         string        `mapstructure:"path"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+}
+
+type LoggingConfig struct {
+	Level      string `mapstructure:"level"`
+	Caller     bool   `mapstructure:"caller"`
+	TraceID    bool   `mapstructure:"trace_id"`
+	Stacktrace string `mapstructure:"stacktrace"`
+}
+
+type ServerConfig struct {
+	Network         string                        `mapstructure:"network"`
+	Address         string                        `mapstructure:"address"`
+	Port            uint16                        `mapstructure:"port"`
+	PublicURL       string                        `mapstructure:"public_url"`
+	Shutdown        ShutdownConfig                `mapstructure:"shutdown"`
+	Health          HealthConfig                  `mapstructure:"health"`
+	TrustedProxies  []string                      `mapstructure:"trusted_proxies"`
+	RemoteIPHeaders []string                      `mapstructure:"remote_ip_headers"`
+	RateLimit       RateLimitConfig               `mapstructure:"rate_limit"`
+	URLSigning      URLSigningConfig              `mapstructure:"url_signing"`
+	Hotlink         HotlinkConfig                 `mapstructure:"hotlink"`
+	Metadata        MetadataConfig                `mapstructure:"metadata"`
+	Batch           BatchConfig                   `mapstructure:"batch"`
+	CacheControl    map[string]CacheControlConfig `mapstructure:"cache_control"`
+}
+
+// StopTimeout is how long the application waits for its stop hooks, which run one after another.
+// The drain delay and the shutdown of the http and grpc servers must fit in it.
+const StopTimeout = 30 * time.Second
+
+type ShutdownConfig struct {
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+}
+
+type HealthConfig struct {
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type RateLimitConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	FallbackCooldown time.Duration `mapstructure:"fallback_cooldown"`
+	IP               LimitConfig   `mapstructure:"ip"`
+	APIKey           APIKeyConfig  `mapstructure:"api_key"`
+}
+
+type LimitConfig struct {
+	Rate   int           `mapstructure:"rate"`
+	Burst  int           `mapstructure:"burst"`
+	Period time.Duration `mapstructure:"period"`
+}
+
+type APIKeyConfig struct {
+	Header string              `mapstructure:"header"`
+	Keys   []APIKeyLimitConfig `mapstructure:"keys"`
+}
+
+type APIKeyLimitConfig struct {
+	Name        string `mapstructure:"name"`
+	Key         string `mapstructure:"key"`
+	LimitConfig `mapstructure:",squash"`
+}
+
+type URLSigningConfig struct {
+	Enabled bool               `mapstructure:"enabled"`
+	Keys    []SigningKeyConfig `mapstructure:"keys"`
+}
+
+type SigningKeyConfig struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+}
+
+type HotlinkConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	Allowed           []string `mapstructure:"allowed"`
+	AllowEmptyReferer bool     `mapstructure:"allow_empty_referer"`
+	Action            string   `mapstructure:"action"`
+	DefaultImage      string   `mapstructure:"default_image"`
+	RedirectURL       string   `mapstructure:"redirect_url"`
+}
+
+type MetadataConfig struct {
+	Tokens []string `mapstructure:"tokens"`
+}
+
+type BatchConfig struct {
+	// Tokens are the bearer tokens of callers trusted with signed urls and inline data uris.
+	Tokens         []string      `mapstructure:"tokens"`
+	MaxHashes      int           `mapstructure:"max_hashes"`
+	Workers        int           `mapstructure:"workers"`
+	InlineMaxBytes int           `mapstructure:"inline_max_bytes"`
+	URLTTL         time.Duration `mapstructure:"url_ttl"`
+}
+
+type CacheControlConfig struct {
+	Public               bool          `mapstructure:"public"`
+	MaxAge               time.Duration `mapstructure:"max_age"`
+	SharedMaxAge         time.Duration `mapstructure:"s_max_age"`
+	Immutable            bool          `mapstructure:"immutable"`
+	NoStore              bool          `mapstructure:"no_store"`
+	StaleWhileRevalidate time.Duration `mapstructure:"stale_while_revalidate"`
+	StaleIfError         time.Duration `mapstructure:"stale_if_error"`
+}
+
+type GRPCConfig struct {
+	Enabled bool            `mapstructure:"enabled"`
+	Network string          `mapstructure:"network"`
+	Address string          `mapstructure:"address"`
+	Port    uint16          `mapstructure:"port"`
+	Batch   GRPCBatchConfig `mapstructure:"batch"`
+}
+
+type GRPCBatchConfig struct {
+	MaxHashes int `mapstructure:"max_hashes"`
+	Workers   int `mapstructure:"workers"`
+}
+
+type AdminConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Listen  string `mapstructure:"listen"`
+}
+
+type ObservabilityConfig struct {
+	Trace  TraceConfig  `mapstructure:"trace"`
+	Metric MetricConfig `mapstructure:"metric"`
+}
+
+type TraceConfig struct {
+	Exporter        TraceExporterConfig `mapstructure:"exporter"`
+	BatchTimeout    time.Duration       `mapstructure:"batch_timeout"`
+	MaxBatchEntries int                 `mapstructure:"max_batch_entries"`
+	ExportTimeout   time.Duration       `mapstructure:"export_timeout"`
+	MaxQueueSize    int                 `mapstructure:"max_queue_size"`
+	SamplingRate    float64             `mapstructure:"sampling_rate"`
+}
+
+type TraceExporterConfig struct {
+	Type     string        `mapstructure:"type"`
+	Endpoint string        `mapstructure:"endpoint"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Insecure bool          `mapstructure:"insecure"`
+}
+
+type MetricConfig struct {
+	Reader MetricReaderConfig `mapstructure:"reader"`
+}
+
+type MetricReaderConfig struct {
+	Type   string `mapstructure:"type"`
+	Listen string `mapstructure:"listen"`
+}
+
+type CassandraConfig struct {
+	Hosts    []string `mapstructure:"hosts"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	Keyspace string   `mapstructure:"keyspace"`
+}
+
+type RedisConfig struct {
+	Mode     string             `mapstructure:"mode"`
+	Host     string             `mapstructure:"host"`
+	Port     uint16             `mapstructure:"port"`
+	Username string             `mapstructure:"username"`
+	Password string             `mapstructure:"password"`
+	Writer   RedisNodeConfig    `mapstructure:"writer"`
+	Reader   RedisNodeConfig    `mapstructure:"reader"`
+	Metrics  RedisMetricsConfig `mapstructure:"metrics"`
+}
+
+type RedisNodeConfig struct {
+	Host string `mapstructure:"host"`
+	Port uint16 `mapstructure:"port"`
+}
+
+type RedisMetricsConfig struct {
+	Namespace string `mapstructure:"namespace"`
+	Subsystem string `mapstructure:"subsystem"`
+}
+
+type CacheConfig struct {
+	Enabled              bool               `mapstructure:"enabled"`
+	TTL                  time.Duration      `mapstructure:"ttl"`
+	NotFoundTTL          time.Duration      `mapstructure:"not_found_ttl"`
+	StaleWhileRevalidate time.Duration      `mapstructure:"stale_while_revalidate"`
+	StaleIfError         time.Duration      `mapstructure:"stale_if_error"`
+	Refresh              CacheRefreshConfig `mapstructure:"refresh"`
+}
+
+type CacheRefreshConfig struct {
+	Workers   int           `mapstructure:"workers"`
+	QueueSize int           `mapstructure:"queue_size"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+type ImagesConfig struct {
+	Resize      ResizeConfig      `mapstructure:"resize"`
+	Placeholder PlaceholderConfig `mapstructure:"placeholder"`
+	Montage     MontageConfig     `mapstructure:"montage"`
+}
+
+type ResizeConfig struct {
+	Filter  string `mapstructure:"filter"`
+	Linear  bool   `mapstructure:"linear"`
+	Upscale string `mapstructure:"upscale"`
+}
+
+type PlaceholderConfig struct {
+	PreviewSize uint `mapstructure:"preview_size"`
+}
+
+type MontageConfig struct {
+	MaxMembers int `mapstructure:"max_members"`
+}
+
+type CDNConfig struct {
+	Purge PurgeConfig `mapstructure:"purge"`
+}
+
+type PurgeConfig struct {
+	Type string          `mapstructure:"type"`
+	HTTP HTTPPurgeConfig `mapstructure:"http"`
+}
+
+type HTTPPurgeConfig struct {
+	URL       string            `mapstructure:"url"`
+	Method    string            `mapstructure:"method"`
+	Headers   map[string]string `mapstructure:"headers"`
+	Timeout   time.Duration     `mapstructure:"timeout"`
+	BatchSize int               `mapstructure:"batch_size"`
+}
+
+type ProfilesConfig struct {
+	QQDisplayName string `mapstructure:"qq_display_name"`
+}
+
+type UpstreamsConfig struct {
+	QQ       QQUpstreamConfig       `mapstructure:"qq"`
+	Gravatar GravatarUpstreamConfig `mapstructure:"gravatar"`
+}
+
+type UpstreamConfig struct {
+	BaseURL               string             `mapstructure:"base_url"`
+	Accept                string             `mapstructure:"accept"`
+	Query                 map[string]string  `mapstructure:"query"`
+	Timeout               time.Duration      `mapstructure:"timeout"`
+	DialTimeout           time.Duration      `mapstructure:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration      `mapstructure:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration      `mapstructure:"response_header_timeout"`
+	Proxy                 string             `mapstructure:"proxy"`
+	TLS                   UpstreamTLSConfig  `mapstructure:"tls"`
+	Pool                  UpstreamPoolConfig `mapstructure:"pool"`
+	MaxBodySize           int64              `mapstructure:"max_body_size"`
+	Mirrors               []string           `mapstructure:"mirrors"`
+	Breaker               BreakerConfig      `mapstructure:"breaker"`
+	Retry                 RetryConfig        `mapstructure:"retry"`
+	Hedge                 HedgeConfig        `mapstructure:"hedge"`
+}
+
+type UpstreamTLSConfig struct {
+	CACert             string `mapstructure:"ca_cert"`
+	ClientCert         string `mapstructure:"client_cert"`
+	ClientKey          string `mapstructure:"client_key"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+type UpstreamPoolConfig struct {
+	MaxIdleConns        int           `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host"`
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+}
+
+type BreakerConfig struct {
+	MaxRequests         uint32        `mapstructure:"max_requests"`
+	Interval            time.Duration `mapstructure:"interval"`
+	Timeout             time.Duration `mapstructure:"timeout"`
+	ConsecutiveFailures uint32        `mapstructure:"consecutive_failures"`
+	MinRequests         uint32        `mapstructure:"min_requests"`
+	FailureRatio        float64       `mapstructure:"failure_ratio"`
+}
+
+type RetryConfig struct {
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	Multiplier     float64       `mapstructure:"multiplier"`
+	Jitter         float64       `mapstructure:"jitter"`
+}
+
+type HedgeConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Percentile float64       `mapstructure:"percentile"`
+	Window     int           `mapstructure:"window"`
+	MinSamples int           `mapstructure:"min_samples"`
+	MinDelay   time.Duration `mapstructure:"min_delay"`
+	MaxDelay   time.Duration `mapstructure:"max_delay"`
+}
+
+type QQUpstreamConfig struct {
+	UpstreamConfig `mapstructure:",squash"`
+	DefaultAvatars DefaultAvatarsConfig `mapstructure:"default_avatars"`
+	ProbeSpec      string               `mapstructure:"probe_spec"`
+	Specs          []int                `mapstructure:"specs"`
+	CoalesceWindow time.Duration        `mapstructure:"coalesce_window"`
+}
+
+type DefaultAvatarsConfig struct {
+	ContentHashes    []string `mapstructure:"content_hashes"`
+	PerceptualHashes []string `mapstructure:"perceptual_hashes"`
+	MaxDistance      int      `mapstructure:"max_distance"`
+}
+
+type GravatarUpstreamConfig struct {
+	UpstreamConfig `mapstructure:",squash"`
+	Profile        ProfileEndpointsConfig `mapstructure:"profile"`
+}
+
+type ProfileEndpointsConfig struct {
+	BaseURL string   `mapstructure:"base_url"`
+	Mirrors []string `mapstructure:"mirrors"`
+}
+
+// Section returns the `upstreams.<name>` section, false when there is no such upstream.
+func (c *UpstreamsConfig) Section(name string) (UpstreamConfig, bool) {
+	switch name {
+	case "qq":
+		return c.QQ.UpstreamConfig, true
+	case "gravatar":
+		return c.Gravatar.UpstreamConfig, true
+	default:
+		return UpstreamConfig{}, false
+	}
+}
+
+// Decode decodes the config of vip, keys the config doesn't know are rejected, then validates it.
+func Decode(vip *viper.Viper) (*Config, error) {
+	var cfg Config
+	if err := vip.UnmarshalExact(&cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Validate reports every missing or invalid key at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Name != "", "name is required")
+
+	if remote := c.Config.Remote; remote.Provider != "" {
+		check(remote.Provider == "etcd3", "config.remote.provider must be etcd3, got %q", remote.Provider)
+		check(remote.Endpoint != "" && remote.Path != "", "config.remote.endpoint and config.remote.path are required with a remote provider")
+		check(remote.PollInterval >= 0, "config.remote.poll_interval must not be negative, got %s", remote.PollInterval)
+	}
+
+	check(c.Server.Port != 0, "server.port is required")
+	check(c.GRPC.Port != 0 || !c.GRPC.Enabled, "grpc.port is required when grpc is enabled")
+	check(c.Admin.Listen != "" || !c.Admin.Enabled, "admin.listen is required when the admin server is enabled")
+	check(c.Server.Shutdown.DrainDelay >= 0, "server.shutdown.drain_delay must not be negative")
+	check(c.Server.Shutdown.Timeout > 0, "server.shutdown.timeout must be positive")
+	shutdown := c.Server.Shutdown.DrainDelay + c.Server.Shutdown.Timeout
+	if c.GRPC.Enabled {
+		shutdown += c.Server.Shutdown.Timeout
+	}
+	check(shutdown < StopTimeout, "server.shutdown.drain_delay and server.shutdown.timeout of the http and grpc servers must add up to less than %s, got %s", StopTimeout, shutdown)
+	check(c.Server.Health.Timeout > 0, "server.health.timeout must be positive")
+	check(c.Server.Batch.MaxHashes > 0, "server.batch.max_hashes must be positive")
+	check(c.GRPC.Batch.MaxHashes > 0, "grpc.batch.max_hashes must be positive")
+
+	if signing := c.Server.URLSigning; signing.Enabled {
+		check(len(signing.Keys) > 0, "server.url_signing.keys must not be empty when url signing is enabled")
+		ids := make(map[string]struct{}, len(signing.Keys))
+		for i, key := range signing.Keys {
+			check(key.ID != "" && key.Secret != "", "server.url_signing.keys[%d] needs an id and a secret", i)
+			_, duplicate := ids[key.ID]
+			check(!duplicate, "server.url_signing.keys[%d] repeats the id %q", i, key.ID)
+			ids[key.ID] = struct{}{}
+		}
+	}
+
+	trace := c.Observability.Trace
+	check(trace.Exporter.Type == "otlp-grpc" || trace.Exporter.Type == "otlp-http",
+		"observability.trace.exporter.type must be otlp-grpc or otlp-http, got %q", trace.Exporter.Type)
+	check(trace.SamplingRate >= 0 && trace.SamplingRate <= 1,
+		"observability.trace.sampling_rate must be between 0 and 1, got %g", trace.SamplingRate)
+	check(trace.MaxBatchEntries > 0, "observability.trace.max_batch_entries must be positive")
+	check(trace.MaxQueueSize > 0, "observability.trace.max_queue_size must be positive")
+	check(c.Observability.Metric.Reader.Type == "prometheus",
+		"observability.metric.reader.type must be prometheus, got %q", c.Observability.Metric.Reader.Type)
+	check(c.Observability.Metric.Reader.Listen != "", "observability.metric.reader.listen is required")
+
+	check(len(c.Cassandra.Hosts) > 0, "cassandra.hosts is required")
+	check(c.Cassandra.Keyspace != "", "cassandra.keyspace is required")
+
+	switch c.Redis.Mode {
+	case "standalone":
+		check(c.Redis.Host != "" && c.Redis.Port != 0, "redis.host and redis.port are required in standalone mode")
+	case "replication":
+		check(c.Redis.Writer.Host != "" && c.Redis.Writer.Port != 0, "redis.writer.host and redis.writer.port are required in replication mode")
+		check(c.Redis.Reader.Host != "" && c.Redis.Reader.Port != 0, "redis.reader.host and redis.reader.port are required in replication mode")
+	default:
+		check(false, "redis.mode must be standalone or replication, got %q", c.Redis.Mode)
+	}
+
+	check(c.CDN.Purge.Type == "none" || c.CDN.Purge.Type == "http", "cdn.purge.type must be none or http, got %q", c.CDN.Purge.Type)
+	check(c.CDN.Purge.Type != "http" || c.CDN.Purge.HTTP.URL != "", "cdn.purge.http.url is required for the http purge type")
+
+	check(c.Upstreams.QQ.BaseURL != "", "upstreams.qq.base_url is required")
+	check(len(c.Upstreams.QQ.Specs) > 0, "upstreams.qq.specs must not be empty")
+	check(c.Upstreams.Gravatar.BaseURL != "", "upstreams.gravatar.base_url is required")
+	check(c.Upstreams.Gravatar.Profile.BaseURL != "", "upstreams.gravatar.profile.base_url is required")
+	check(c.Upstreams.QQ.Timeout >= 0, "upstreams.qq.timeout must not be negative, got %s", c.Upstreams.QQ.Timeout)
+	check(c.Upstreams.Gravatar.Timeout >= 0, "upstreams.gravatar.timeout must not be negative, got %s", c.Upstreams.Gravatar.Timeout)
+
+	for name, upstream := range map[string]UpstreamConfig{"qq": c.Upstreams.QQ.UpstreamConfig, "gravatar": c.Upstreams.Gravatar.UpstreamConfig} {
+		if retry := upstream.Retry; retry.MaxAttempts > 1 {
+			check(retry.InitialBackoff > 0 && retry.MaxBackoff >= retry.InitialBackoff,
+				"upstreams.%s.retry.initial_backoff must be positive and at most max_backoff", name)
+			check(retry.Multiplier >= 1, "upstreams.%s.retry.multiplier must be at least 1, got %g", name, retry.Multiplier)
+			check(retry.Jitter >= 0 && retry.Jitter <= 1, "upstreams.%s.retry.jitter must be between 0 and 1, got %g", name, retry.Jitter)
+		}
+
+		// until min_samples latencies are observed every request is hedged after max_delay,
+		// so a zero max_delay would send every request twice
+		if hedge := upstream.Hedge; hedge.Enabled {
+			check(hedge.Percentile > 0 && hedge.Percentile <= 1, "upstreams.%s.hedge.percentile must be between 0 and 1, got %g", name, hedge.Percentile)
+			check(hedge.MinSamples > 0 && hedge.Window >= hedge.MinSamples,
+				"upstreams.%s.hedge.min_samples must be positive and at most the window", name)
+			check(hedge.MaxDelay > 0 && hedge.MinDelay <= hedge.MaxDelay,
+				"upstreams.%s.hedge.max_delay must be positive and at least min_delay", name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
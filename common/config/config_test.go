@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode_Example(t *testing.T) {
+	asserts := assert.New(t)
+
+	vip, err := readCandidate([]byte(readExample(t)))
+	asserts.NoError(err)
+
+	conf, err := Decode(vip)
+	asserts.NoError(err)
+	asserts.EqualValues(8080, conf.Server.Port)
+	asserts.Equal(0.2, conf.Observability.Trace.SamplingRate)
+	asserts.Equal([]int{40, 100, 140, 640}, conf.Upstreams.QQ.Specs)
+
+	gravatar, ok := conf.Upstreams.Section("gravatar")
+	asserts.True(ok)
+	asserts.NotEmpty(gravatar.BaseURL)
+}
+
+func TestDecode_UnknownKey(t *testing.T) {
+	asserts := assert.New(t)
+
+	content := strings.Replace(readExample(t), "  port: 8080", "  port: 8080\n  prot: 8081", 1)
+	vip, err := readCandidate([]byte(content))
+	asserts.NoError(err)
+
+	_, err = Decode(vip)
+	asserts.ErrorContains(err, "prot")
+}
+
+func TestDecode_MissingKeys(t *testing.T) {
+	asserts := assert.New(t)
+
+	vip, err := readCandidate([]byte("server:\n  port: 0\nupstreams:\n  qq:\n    specs: []\n"))
+	asserts.NoError(err)
+
+	// every missing key is reported at once
+	_, err = Decode(vip)
+	asserts.ErrorContains(err, "server.port is required")
+	asserts.ErrorContains(err, "cassandra.hosts is required")
+	asserts.ErrorContains(err, "cassandra.keyspace is required")
+	asserts.ErrorContains(err, "upstreams.qq.specs must not be empty")
+}
+
+func TestValidate_URLSigningKeys(t *testing.T) {
+	asserts := assert.New(t)
+
+	content := strings.Replace(readExample(t), "  url_signing:\n    enabled: false", "  url_signing:\n    enabled: true", 1)
+	vip, err := readCandidate([]byte(content))
+	asserts.NoError(err)
+	_, err = Decode(vip)
+	asserts.NoError(err)
+
+	vip.Set("server.url_signing.keys", []map[string]interface{}{})
+	_, err = Decode(vip)
+	asserts.ErrorContains(err, "server.url_signing.keys must not be empty")
+
+	vip.Set("server.url_signing.keys", []map[string]interface{}{{"id": "a", "secret": ""}, {"id": "a", "secret": "s"}})
+	_, err = Decode(vip)
+	asserts.ErrorContains(err, "server.url_signing.keys[0] needs an id and a secret")
+	asserts.ErrorContains(err, `server.url_signing.keys[1] repeats the id "a"`)
+}
+
+func TestValidate_Hedge(t *testing.T) {
+	asserts := assert.New(t)
+
+	vip, err := readCandidate([]byte(readExample(t)))
+	asserts.NoError(err)
+
+	vip.Set("upstreams.qq.hedge.enabled", true)
+	_, err = Decode(vip)
+	asserts.NoError(err)
+
+	vip.Set("upstreams.qq.hedge.max_delay", "0s")
+	vip.Set("upstreams.qq.hedge.min_samples", 0)
+	vip.Set("upstreams.gravatar.retry.multiplier", 0.5)
+	_, err = Decode(vip)
+	asserts.ErrorContains(err, "upstreams.qq.hedge.max_delay must be positive")
+	asserts.ErrorContains(err, "upstreams.qq.hedge.min_samples must be positive")
+	asserts.ErrorContains(err, "upstreams.gravatar.retry.multiplier must be at least 1")
+}
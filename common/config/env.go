@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes the environment variables overriding the config. A key maps to the upper-cased variable
+// with `.` replaced by `_`, e.g. `cassandra.password` is set by `GRAVATAR_QQ_CASSANDRA_PASSWORD`.
+const EnvPrefix = "GRAVATAR_QQ"
+
+// fileEnvSuffix marks a variable holding the path of a file to read the value from,
+// e.g. `GRAVATAR_QQ_CASSANDRA_PASSWORD_FILE=/run/secrets/cassandra_password` for a docker or kubernetes secret.
+const fileEnvSuffix = "_FILE"
+
+var envKeyReplacer = strings.NewReplacer(".", "_")
+
+// bindEnv makes every key of Config settable from the environment, directly or through a `_FILE` variable.
+func bindEnv(vip *viper.Viper) error {
+	vip.SetEnvPrefix(EnvPrefix)
+	vip.SetEnvKeyReplacer(envKeyReplacer)
+	vip.AutomaticEnv()
+
+	var errs []error
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		// a key only set in the environment is unknown to viper, and left out when decoding, unless it is bound
+		if err := vip.BindEnv(key); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		env := envName(key)
+		filename, ok := os.LookupEnv(env + fileEnvSuffix)
+		if !ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(env); ok {
+			errs = append(errs, fmt.Errorf("%s and %s%s are both set", env, env, fileEnvSuffix))
+			continue
+		}
+
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read %s%s: %w", env, fileEnvSuffix, err))
+			continue
+		}
+
+		// secret files usually end with a newline which isn't part of the value
+		vip.Set(key, strings.TrimRight(string(content), "\r\n"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(envKeyReplacer.Replace(key))
+}
+
+// configKeys lists the keys of the fields of t, maps are left out as their keys aren't known upfront.
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+
+		switch {
+		case opts == "squash":
+			keys = append(keys, configKeys(field.Type, prefix)...)
+		case field.Type.Kind() == reflect.Struct:
+			keys = append(keys, configKeys(field.Type, prefix+name+".")...)
+		case field.Type.Kind() != reflect.Map:
+			keys = append(keys, prefix+name)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeExample(t *testing.T) (*Config, error) {
+	vip, err := readCandidate([]byte(readExample(t)))
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(vip)
+}
+
+func TestBindEnv_Override(t *testing.T) {
+	asserts := assert.New(t)
+
+	t.Setenv("GRAVATAR_QQ_SERVER_PORT", "9000")
+	t.Setenv("GRAVATAR_QQ_SERVER_SHUTDOWN_DRAIN_DELAY", "1s")
+	// unprefixed variables are ignored
+	t.Setenv("CASSANDRA_KEYSPACE", "other")
+
+	conf, err := decodeExample(t)
+	asserts.NoError(err)
+	asserts.EqualValues(9000, conf.Server.Port)
+	asserts.Equal(time.Second, conf.Server.Shutdown.DrainDelay)
+	asserts.Equal("gravatar", conf.Cassandra.Keyspace)
+}
+
+func TestBindEnv_List(t *testing.T) {
+	asserts := assert.New(t)
+
+	t.Setenv("GRAVATAR_QQ_CASSANDRA_HOSTS", "cassandra-0:9042,cassandra-1:9042")
+
+	conf, err := decodeExample(t)
+	asserts.NoError(err)
+	asserts.Equal([]string{"cassandra-0:9042", "cassandra-1:9042"}, conf.Cassandra.Hosts)
+}
+
+func TestBindEnv_File(t *testing.T) {
+	asserts := assert.New(t)
+
+	filename := filepath.Join(t.TempDir(), "cassandra_password")
+	asserts.NoError(os.WriteFile(filename, []byte("s3cret\r\n"), 0o600))
+	t.Setenv("GRAVATAR_QQ_CASSANDRA_PASSWORD_FILE", filename)
+
+	conf, err := decodeExample(t)
+	asserts.NoError(err)
+	asserts.Equal("s3cret", conf.Cassandra.Password)
+
+	// the value and the file are ambiguous
+	t.Setenv("GRAVATAR_QQ_CASSANDRA_PASSWORD", "other")
+	_, err = decodeExample(t)
+	asserts.ErrorContains(err, "GRAVATAR_QQ_CASSANDRA_PASSWORD and GRAVATAR_QQ_CASSANDRA_PASSWORD_FILE are both set")
+
+	asserts.NoError(os.Unsetenv("GRAVATAR_QQ_CASSANDRA_PASSWORD"))
+	t.Setenv("GRAVATAR_QQ_CASSANDRA_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err = decodeExample(t)
+	asserts.ErrorContains(err, "read GRAVATAR_QQ_CASSANDRA_PASSWORD_FILE")
+}
@@ -0,0 +1,354 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Change is a config key whose value changed.
+type Change struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// ChangeEvent is published to the subscribers of the changed keys, Config holds the whole new config.
+type ChangeEvent struct {
+	Changes []Change
+	Config  *Config
+}
+
+// Changed reports whether the key or a key under it changed.
+func (e ChangeEvent) Changed(key string) bool {
+	for _, change := range e.Changes {
+		if matchKey(key, change.Key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ApplyFunc validates the new config and returns the function switching to it, which must not fail.
+// An error rejects the whole change, so no subscriber applies it.
+type ApplyFunc func(ctx context.Context, event ChangeEvent) (commit func(), err error)
+
+type subscriber struct {
+	name  string
+	keys  []string
+	apply ApplyFunc
+}
+
+// Reloader watches the config file and the remote config, and publishes the changes to the modules applying them live.
+// A change is applied all or nothing: when a changed key has no subscriber or a subscriber rejects it,
+// the running config is left as it is and the change is logged until the next restart.
+// The viper instance is only read at the start, the running config is swapped as a whole on every change.
+type Reloader struct {
+	filename string
+	current  atomic.Pointer[snapshot]
+	done     chan struct{}
+
+	mu          sync.Mutex
+	subscribers []subscriber
+}
+
+// snapshot is the running config, it is never changed once published.
+type snapshot struct {
+	settings map[string]interface{}
+	config   *Config
+}
+
+func NewReloader(ctx context.Context, vip *viper.Viper, conf *Config, lc fx.Lifecycle) *Reloader {
+	ctx, span := tracer.Start(ctx, "config.NewReloader")
+	defer span.End()
+
+	r := &Reloader{filename: vip.ConfigFileUsed(), done: make(chan struct{})}
+	r.current.Store(&snapshot{settings: vip.AllSettings(), config: conf})
+
+	if !conf.Config.Watch || r.filename == "" {
+		return r
+	}
+
+	lc.Append(fx.Hook{
+		// every module subscribed while it was constructed
+		OnStart: func(ctx context.Context) error {
+			r.watch(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(r.done)
+			return nil
+		},
+	})
+
+	return r
+}
+
+// Settings returns the running settings, including the changes applied since the start.
+// The returned map is shared and must not be modified.
+func (r *Reloader) Settings() map[string]interface{} {
+	return r.current.Load().settings
+}
+
+// Subscribe applies the changes of the keys, and of the keys under them, with apply.
+func (r *Reloader) Subscribe(name string, keys []string, apply ApplyFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, subscriber{name: name, keys: keys, apply: apply})
+}
+
+func (r *Reloader) watch(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "config.Reloader.watch")
+	defer span.End()
+
+	filename := r.filename
+	remote := r.current.Load().config.Config.Remote
+
+	// the running config only changes once the whole change is accepted,
+	// so the file is watched through another instance
+	watcher := viper.New()
+	watcher.SetConfigFile(filename)
+	watcher.OnConfigChange(func(e fsnotify.Event) {
+		r.reloadFile(context.Background(), filename)
+	})
+	watcher.WatchConfig()
+
+	otelzap.L().Ctx(ctx).Info("watching config file", zap.String("file", filename))
+
+	interval := remote.PollInterval
+	if remote.Provider == "" || interval <= 0 {
+		return
+	}
+
+	// the remote config is read again along with the file
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				r.reloadFile(context.Background(), filename)
+			}
+		}
+	}()
+
+	otelzap.L().Ctx(ctx).Info("polling remote config",
+		zap.String("provider", remote.Provider),
+		zap.String("path", remote.Path),
+		zap.Duration("interval", interval),
+	)
+}
+
+func (r *Reloader) reloadFile(ctx context.Context, filename string) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Error("read changed config failed", zap.String("file", filename), zap.Error(err))
+		return
+	}
+
+	_ = r.Reload(ctx, content)
+}
+
+// Reload applies the config content when every changed key is accepted by its subscribers.
+func (r *Reloader) Reload(ctx context.Context, content []byte) error {
+	ctx, span := tracer.Start(ctx, "config.Reloader.Reload")
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	candidate, err := readCandidate(content)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("config change rejected, read config failed", zap.Error(err))
+		return err
+	}
+
+	cfg, err := Decode(candidate)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("config change rejected", zap.Error(err))
+		return err
+	}
+
+	next := &snapshot{settings: candidate.AllSettings(), config: cfg}
+	changes := diff(r.current.Load().settings, next.settings)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(changes))
+	for _, change := range changes {
+		keys = append(keys, change.Key)
+	}
+
+	if unsupported := r.unsupported(changes); len(unsupported) > 0 {
+		err := &RestartRequiredError{Keys: unsupported}
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("config change rejected, restart to apply it",
+			zap.Strings("keys", keys),
+			zap.Strings("restart_required", unsupported),
+		)
+		return err
+	}
+
+	commits := make([]func(), 0, len(r.subscribers))
+	for _, sub := range r.subscribers {
+		event := ChangeEvent{Config: cfg}
+		for _, change := range changes {
+			if sub.matches(change.Key) {
+				event.Changes = append(event.Changes, change)
+			}
+		}
+		if len(event.Changes) == 0 {
+			continue
+		}
+
+		commit, err := sub.apply(ctx, event)
+		if err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("config change rejected",
+				zap.String("subscriber", sub.name),
+				zap.Strings("keys", keys),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		commits = append(commits, commit)
+	}
+
+	r.current.Store(next)
+	for _, commit := range commits {
+		commit()
+	}
+
+	otelzap.L().Ctx(ctx).Info("config change applied", zap.Strings("keys", keys))
+	return nil
+}
+
+// readCandidate reads the config content with the defaults, the remote config and the environment
+// layered the way NewViper does.
+func readCandidate(content []byte) (*viper.Viper, error) {
+	candidate := viper.New()
+	candidate.SetConfigType(configType)
+	setDefaults(candidate)
+	if err := bindEnv(candidate); err != nil {
+		return nil, err
+	}
+
+	if err := candidate.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	remote, err := readRemote(candidate)
+	if err != nil {
+		return nil, err
+	}
+	if err := candidate.MergeConfigMap(remote); err != nil {
+		return nil, fmt.Errorf("merge remote config: %w", err)
+	}
+
+	return candidate, nil
+}
+
+// unsupported returns the changed keys no subscriber applies.
+func (r *Reloader) unsupported(changes []Change) []string {
+	var keys []string
+	for _, change := range changes {
+		supported := false
+		for _, sub := range r.subscribers {
+			if sub.matches(change.Key) {
+				supported = true
+				break
+			}
+		}
+
+		if !supported {
+			keys = append(keys, change.Key)
+		}
+	}
+
+	return keys
+}
+
+func (s subscriber) matches(key string) bool {
+	for _, k := range s.keys {
+		if matchKey(k, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchKey reports whether key is prefix or a key under it.
+func matchKey(prefix, key string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+".")
+}
+
+// diff returns the keys whose value differs between the settings, sorted.
+func diff(old, new map[string]interface{}) []Change {
+	oldValues, newValues := make(map[string]interface{}), make(map[string]interface{})
+	flatten(old, "", oldValues)
+	flatten(new, "", newValues)
+
+	keys := make(map[string]struct{}, len(oldValues))
+	for key := range oldValues {
+		keys[key] = struct{}{}
+	}
+	for key := range newValues {
+		keys[key] = struct{}{}
+	}
+
+	var changes []Change
+	for key := range keys {
+		if o, n := oldValues[key], newValues[key]; !reflect.DeepEqual(o, n) {
+			changes = append(changes, Change{Key: key, Old: o, New: n})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
+
+// flatten collects the leaf values of the nested settings by their dotted keys.
+func flatten(settings map[string]interface{}, prefix string, values map[string]interface{}) {
+	for key, value := range settings {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(nested, prefix+key+".", values)
+		} else {
+			values[prefix+key] = value
+		}
+	}
+}
+
+// RestartRequiredError rejects a change of keys that can't be applied live.
+type RestartRequiredError struct {
+	Keys []string
+}
+
+func (e *RestartRequiredError) Error() string {
+	return "restart required to apply " + strings.Join(e.Keys, ", ")
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxtest"
+)
+
+func readExample(t *testing.T) string {
+	content, err := os.ReadFile("../../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func newTestReloader(t *testing.T, content string) *Reloader {
+	vip, err := readCandidate([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := Decode(vip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewReloader(context.Background(), vip, conf, fxtest.NewLifecycle(t))
+}
+
+func setting(r *Reloader, key string) interface{} {
+	values := make(map[string]interface{})
+	flatten(r.Settings(), "", values)
+	return values[key]
+}
+
+func TestReloader_RestartRequired(t *testing.T) {
+	asserts := assert.New(t)
+
+	example := readExample(t)
+	r := newTestReloader(t, example)
+
+	applied := false
+	r.Subscribe("tracing", []string{"observability.trace.sampling_rate"}, func(ctx context.Context, event ChangeEvent) (func(), error) {
+		applied = true
+		return func() {}, nil
+	})
+
+	changed := strings.Replace(example, "sampling_rate: 0.2", "sampling_rate: 0.5", 1)
+	changed = strings.Replace(changed, "port: 8080", "port: 8081", 1)
+
+	err := r.Reload(context.Background(), []byte(changed))
+	var restart *RestartRequiredError
+	asserts.ErrorAs(err, &restart)
+	asserts.Equal([]string{"server.port"}, restart.Keys)
+
+	// the supported key of the change isn't applied either
+	asserts.False(applied)
+	asserts.EqualValues(8080, setting(r, "server.port"))
+	asserts.EqualValues(0.2, setting(r, "observability.trace.sampling_rate"))
+}
+
+func TestReloader_Rollback(t *testing.T) {
+	asserts := assert.New(t)
+
+	example := readExample(t)
+	r := newTestReloader(t, example)
+
+	var committed []string
+	r.Subscribe("tracing", []string{"observability.trace.sampling_rate"}, func(ctx context.Context, event ChangeEvent) (func(), error) {
+		return func() { committed = append(committed, "tracing") }, nil
+	})
+	rejected := errors.New("rejected")
+	r.Subscribe("logging", []string{"logging.level"}, func(ctx context.Context, event ChangeEvent) (func(), error) {
+		return nil, rejected
+	})
+
+	changed := strings.Replace(example, "sampling_rate: 0.2", "sampling_rate: 0.5", 1)
+	changed = strings.Replace(changed, `level: ""`, `level: "debug"`, 1)
+
+	err := r.Reload(context.Background(), []byte(changed))
+	asserts.ErrorIs(err, rejected)
+
+	// the subscriber accepting the change never commits it and the running config is kept
+	asserts.Empty(committed)
+	asserts.EqualValues(0.2, setting(r, "observability.trace.sampling_rate"))
+	asserts.Equal("", setting(r, "logging.level"))
+}
+
+func TestReloader_Commit(t *testing.T) {
+	asserts := assert.New(t)
+
+	example := readExample(t)
+	r := newTestReloader(t, example)
+
+	var committed []string
+	r.Subscribe("tracing", []string{"observability.trace"}, func(ctx context.Context, event ChangeEvent) (func(), error) {
+		asserts.Equal([]Change{{Key: "observability.trace.sampling_rate", Old: 0.2, New: 0.5}}, event.Changes)
+		asserts.Equal(0.5, event.Config.Observability.Trace.SamplingRate)
+
+		return func() {
+			// the new config is published before the subscribers switch to it
+			asserts.EqualValues(0.5, setting(r, "observability.trace.sampling_rate"))
+			committed = append(committed, "tracing")
+		}, nil
+	})
+	r.Subscribe("logging", []string{"logging.level"}, func(ctx context.Context, event ChangeEvent) (func(), error) {
+		asserts.True(event.Changed("logging"))
+		asserts.False(event.Changed("observability.trace"))
+
+		return func() { committed = append(committed, "logging") }, nil
+	})
+	r.Subscribe("hotlink", []string{"server.hotlink"}, func(ctx context.Context, event ChangeEvent) (func(), error) {
+		t.Error("subscriber without changed keys called")
+		return func() {}, nil
+	})
+
+	changed := strings.Replace(example, "sampling_rate: 0.2", "sampling_rate: 0.5", 1)
+	changed = strings.Replace(changed, `level: ""`, `level: "debug"`, 1)
+
+	asserts.NoError(r.Reload(context.Background(), []byte(changed)))
+	asserts.Equal([]string{"tracing", "logging"}, committed)
+	asserts.Equal("debug", setting(r, "logging.level"))
+
+	// reloading the same content changes nothing
+	asserts.NoError(r.Reload(context.Background(), []byte(changed)))
+	asserts.Len(committed, 2)
+}
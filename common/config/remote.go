@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	_ "github.com/spf13/viper/remote"
+)
+
+// readRemote reads the config stored at `config.remote.path` of the remote provider, nil when there is none.
+// The remote config is layered over the config file, the environment still overrides both.
+func readRemote(vip *viper.Viper) (map[string]interface{}, error) {
+	provider := vip.GetString("config.remote.provider")
+	if provider == "" {
+		return nil, nil
+	}
+
+	remote := viper.New()
+	remote.SetConfigType(configType)
+	if err := remote.AddRemoteProvider(provider, vip.GetString("config.remote.endpoint"), vip.GetString("config.remote.path")); err != nil {
+		return nil, fmt.Errorf("add remote config provider: %w", err)
+	}
+
+	if err := remote.ReadRemoteConfig(); err != nil {
+		return nil, fmt.Errorf("read remote config from %s %s: %w", provider, vip.GetString("config.remote.path"), err)
+	}
+
+	return remote.AllSettings(), nil
+}
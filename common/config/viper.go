@@ -12,11 +12,196 @@ import (
 
 var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/internal/config")
 
+const configType = "yaml"
+
 var defaultConfig = map[string]interface{}{
 	"name":        "gravatar-with-qq-avatar",
 	"namespace":   "gravatar-with-qq-avatar",
 	"version":     "0.0.1",
-	"instance_id": uuid.New(),
+	"instance_id": uuid.NewString(),
+
+	"config.watch":                true,
+	"config.remote.provider":      "",
+	"config.remote.endpoint":      "",
+	"config.remote.path":          "",
+	"config.remote.poll_interval": "30s",
+
+	"logging.level":      "",
+	"logging.caller":     true,
+	"logging.trace_id":   true,
+	"logging.stacktrace": "error",
+
+	"server.network":                      "tcp",
+	"server.address":                      "0.0.0.0",
+	"server.port":                         8080,
+	"server.remote_ip_headers":            []string{"X-Forwarded-For", "X-Real-IP"},
+	"server.trusted_proxies":              []string{},
+	"server.rate_limit.enabled":           false,
+	"server.rate_limit.fallback_cooldown": "10s",
+	"server.rate_limit.api_key.header":    "X-Api-Key",
+	"server.url_signing.enabled":          false,
+	"server.hotlink.enabled":              false,
+	"server.hotlink.allowed":              []string{},
+	"server.hotlink.allow_empty_referer":  true,
+	"server.hotlink.action":               "forbid",
+	"server.metadata.tokens":              []string{},
+	"server.public_url":                   "",
+	"server.shutdown.drain_delay":         "5s",
+	"server.shutdown.timeout":             "8s",
+	"server.health.timeout":               "2s",
+	"server.batch.tokens":                 []string{},
+	"server.batch.max_hashes":             200,
+	"server.batch.workers":                16,
+	"server.batch.inline_max_bytes":       4096,
+	"server.batch.url_ttl":                "24h",
+
+	"server.cache_control.qq.public":                       true,
+	"server.cache_control.qq.max_age":                      "1h",
+	"server.cache_control.qq.stale_while_revalidate":       "1h",
+	"server.cache_control.qq.stale_if_error":               "24h",
+	"server.cache_control.gravatar.public":                 true,
+	"server.cache_control.gravatar.max_age":                "1h",
+	"server.cache_control.gravatar.stale_while_revalidate": "1h",
+	"server.cache_control.gravatar.stale_if_error":         "24h",
+	"server.cache_control.default.public":                  true,
+	"server.cache_control.default.max_age":                 "10m",
+	"server.cache_control.default.stale_while_revalidate":  "10m",
+	"server.cache_control.default.stale_if_error":          "24h",
+	"server.cache_control.not_found.public":                true,
+	"server.cache_control.not_found.max_age":               "1m",
+
+	"cache.enabled":                true,
+	"cache.ttl":                    "10m",
+	"cache.not_found_ttl":          "1m",
+	"cache.stale_while_revalidate": "1h",
+	"cache.stale_if_error":         "24h",
+	"cache.refresh.workers":        4,
+	"cache.refresh.queue_size":     256,
+	"cache.refresh.timeout":        "15s",
+
+	"observability.trace.exporter.type":     "otlp-grpc",
+	"observability.trace.exporter.endpoint": "localhost:4317",
+	"observability.trace.exporter.timeout":  "10s",
+	"observability.trace.batch_timeout":     "5s",
+	"observability.trace.max_batch_entries": 512,
+	"observability.trace.export_timeout":    "30s",
+	"observability.trace.max_queue_size":    2048,
+	"observability.trace.sampling_rate":     0.2,
+	"observability.metric.reader.type":      "prometheus",
+	"observability.metric.reader.listen":    "0.0.0.0:9201",
+
+	"redis.mode":              "standalone",
+	"redis.port":              6379,
+	"redis.metrics.namespace": "gravatar",
+	"redis.metrics.subsystem": "redis",
+
+	"admin.enabled": true,
+	"admin.listen":  "127.0.0.1:6060",
+
+	"grpc.enabled":          true,
+	"grpc.network":          "tcp",
+	"grpc.address":          "127.0.0.1",
+	"grpc.port":             9090,
+	"grpc.batch.max_hashes": 200,
+	"grpc.batch.workers":    16,
+
+	"images.resize.filter":            "lanczos3",
+	"images.resize.linear":            true,
+	"images.resize.upscale":           "cap",
+	"images.placeholder.preview_size": 16,
+	"images.montage.max_members":      16,
+
+	"cdn.purge.type":            "none",
+	"cdn.purge.http.method":     "POST",
+	"cdn.purge.http.timeout":    "10s",
+	"cdn.purge.http.batch_size": 100,
+
+	"upstreams.qq.base_url":                     "https://q.qlogo.cn/",
+	"upstreams.qq.accept":                       "image/jpeg",
+	"upstreams.qq.query":                        map[string]string{"dst_uin": "0", "spec": "640", "img_type": "jpg"},
+	"upstreams.qq.timeout":                      "10s",
+	"upstreams.qq.dial_timeout":                 "5s",
+	"upstreams.qq.tls_handshake_timeout":        "5s",
+	"upstreams.qq.response_header_timeout":      "5s",
+	"upstreams.qq.pool.max_idle_conns":          100,
+	"upstreams.qq.pool.max_idle_conns_per_host": 16,
+	"upstreams.qq.pool.max_conns_per_host":      0,
+	"upstreams.qq.pool.idle_conn_timeout":       "90s",
+	"upstreams.qq.max_body_size":                5 << 20,
+	"upstreams.qq.breaker.max_requests":         1,
+	"upstreams.qq.breaker.interval":             "1m",
+	"upstreams.qq.breaker.timeout":              "30s",
+	"upstreams.qq.breaker.consecutive_failures": 5,
+	"upstreams.qq.breaker.min_requests":         20,
+	"upstreams.qq.breaker.failure_ratio":        0.5,
+	"upstreams.qq.retry.max_attempts":           3,
+	"upstreams.qq.retry.initial_backoff":        "100ms",
+	"upstreams.qq.retry.max_backoff":            "2s",
+	"upstreams.qq.retry.multiplier":             2,
+	"upstreams.qq.retry.jitter":                 0.5,
+	"upstreams.qq.hedge.enabled":                false,
+	"upstreams.qq.hedge.percentile":             0.95,
+	"upstreams.qq.hedge.window":                 1000,
+	"upstreams.qq.hedge.min_samples":            100,
+	"upstreams.qq.hedge.min_delay":              "50ms",
+	"upstreams.qq.hedge.max_delay":              "1s",
+	"upstreams.qq.default_avatars.max_distance": 4,
+	"upstreams.qq.probe_spec":                   "40",
+	"upstreams.qq.specs":                        []int{40, 100, 140, 640},
+	"upstreams.qq.coalesce_window":              "0s",
+
+	"upstreams.gravatar.base_url":                     "https://gravatar.com/avatar/",
+	"upstreams.gravatar.accept":                       "image/jpeg",
+	"upstreams.gravatar.query":                        map[string]string{"s": "80"},
+	"upstreams.gravatar.timeout":                      "10s",
+	"upstreams.gravatar.dial_timeout":                 "5s",
+	"upstreams.gravatar.tls_handshake_timeout":        "5s",
+	"upstreams.gravatar.response_header_timeout":      "5s",
+	"upstreams.gravatar.pool.max_idle_conns":          100,
+	"upstreams.gravatar.pool.max_idle_conns_per_host": 16,
+	"upstreams.gravatar.pool.max_conns_per_host":      0,
+	"upstreams.gravatar.pool.idle_conn_timeout":       "90s",
+	"upstreams.gravatar.max_body_size":                5 << 20,
+	"upstreams.gravatar.breaker.max_requests":         1,
+	"upstreams.gravatar.breaker.interval":             "1m",
+	"upstreams.gravatar.breaker.timeout":              "30s",
+	"upstreams.gravatar.breaker.consecutive_failures": 5,
+	"upstreams.gravatar.breaker.min_requests":         20,
+	"upstreams.gravatar.breaker.failure_ratio":        0.5,
+	"upstreams.gravatar.retry.max_attempts":           3,
+	"upstreams.gravatar.retry.initial_backoff":        "100ms",
+	"upstreams.gravatar.retry.max_backoff":            "2s",
+	"upstreams.gravatar.retry.multiplier":             2,
+	"upstreams.gravatar.retry.jitter":                 0.5,
+	"upstreams.gravatar.hedge.enabled":                false,
+	"upstreams.gravatar.hedge.percentile":             0.95,
+	"upstreams.gravatar.hedge.window":                 1000,
+	"upstreams.gravatar.hedge.min_samples":            100,
+	"upstreams.gravatar.hedge.min_delay":              "50ms",
+	"upstreams.gravatar.hedge.max_delay":              "1s",
+	"upstreams.gravatar.profile.base_url":             "https://gravatar.com/",
+
+	"profiles.qq_display_name": "QQ User",
+}
+
+func NewConfig(ctx context.Context, vip *viper.Viper) (*Config, error) {
+	ctx, span := tracer.Start(ctx, "config.NewConfig")
+	defer span.End()
+
+	cfg, err := Decode(vip)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("failed to load config", zap.Error(err))
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func setDefaults(vip *viper.Viper) {
+	for k, v := range defaultConfig {
+		vip.SetDefault(k, v)
+	}
 }
 
 func NewViper(ctx context.Context) (*viper.Viper, error) {
@@ -24,17 +209,20 @@ func NewViper(ctx context.Context) (*viper.Viper, error) {
 	defer span.End()
 
 	vip := viper.GetViper()
-	vip.AutomaticEnv()
 
 	vip.SetConfigName("config")
-	vip.SetConfigType("yaml")
+	vip.SetConfigType(configType)
 	vip.AddConfigPath(".")
 	vip.AddConfigPath("./config")
 	vip.AddConfigPath("/etc/gravatar-with-qq-avatar")
 	vip.AddConfigPath("$HOME/.gravatar-with-qq-avatar")
 
-	for k, v := range defaultConfig {
-		vip.SetDefault(k, v)
+	setDefaults(vip)
+
+	if err := bindEnv(vip); err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("failed to read config from the environment", zap.Error(err))
+		return nil, err
 	}
 
 	if err := vip.ReadInConfig(); err != nil {
@@ -43,5 +231,17 @@ func NewViper(ctx context.Context) (*viper.Viper, error) {
 		return nil, err
 	}
 
+	remote, err := readRemote(vip)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("failed to read remote config", zap.Error(err))
+		return nil, err
+	}
+	if err := vip.MergeConfigMap(remote); err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("failed to merge remote config", zap.Error(err))
+		return nil, err
+	}
+
 	return vip, nil
 }
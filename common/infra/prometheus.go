@@ -8,10 +8,11 @@ import (
 	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/spf13/viper"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 )
 
 type PrometheusLogger struct {
@@ -22,14 +23,14 @@ func (l *PrometheusLogger) Println(v ...interface{}) {
 	l.logger.Sugar().Error(v...)
 }
 
-func NewPrometheusRegistry(ctx context.Context, lc fx.Lifecycle, vip *viper.Viper) (*promclient.Registry, error) {
+func NewPrometheusRegistry(ctx context.Context, lc fx.Lifecycle, conf *config.Config) (*promclient.Registry, error) {
 	ctx, span := tracer.Start(ctx, "infra.NewPrometheusRegistry")
 	defer span.End()
 
 	registry := promclient.NewRegistry()
 
 	svr := &http.Server{
-		Addr: vip.GetString("observability.metric.reader.listen"),
+		Addr: conf.Observability.Metric.Reader.Listen,
 		Handler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{
 			Registry:      registry,
 			ErrorHandling: promhttp.HTTPErrorOnError,
@@ -9,31 +9,32 @@ import (
 	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/extra/redisprometheus/v9"
 	"github.com/redis/go-redis/v9"
-	"github.com/spf13/viper"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 )
 
-func NewRedisClient(ctx context.Context, vip *viper.Viper) (redis.UniversalClient, error) {
+func NewRedisClient(ctx context.Context, conf *config.Config) (redis.UniversalClient, error) {
 	ctx, span := tracer.Start(ctx, "infra.NewRedisClient")
 	defer span.End()
 
 	var universalClient redis.UniversalClient
-	switch vip.GetString("redis.mode") {
+	switch conf.Redis.Mode {
 	case "standalone":
 		client := redis.NewClient(&redis.Options{
 			Network:  "tcp",
-			Addr:     fmt.Sprintf("%s:%d", vip.GetString("redis.host"), vip.GetUint16("redis.port")),
-			Username: vip.GetString("redis.username"),
-			Password: vip.GetString("redis.password"),
+			Addr:     fmt.Sprintf("%s:%d", conf.Redis.Host, conf.Redis.Port),
+			Username: conf.Redis.Username,
+			Password: conf.Redis.Password,
 			DB:       0,
 		})
 
 		universalClient = client
 	case "replication":
 		c := redis.NewClusterClient(&redis.ClusterOptions{
-			Username:      vip.GetString("redis.username"),
-			Password:      vip.GetString("redis.password"),
+			Username:      conf.Redis.Username,
+			Password:      conf.Redis.Password,
 			RouteRandomly: true,
 			ClusterSlots: func(_ context.Context) ([]redis.ClusterSlot, error) {
 				return []redis.ClusterSlot{
@@ -42,10 +43,10 @@ func NewRedisClient(ctx context.Context, vip *viper.Viper) (redis.UniversalClien
 						End:   16383,
 						Nodes: []redis.ClusterNode{
 							{
-								Addr: fmt.Sprintf("%s:%d", vip.GetString("redis.writer.host"), vip.GetUint16("redis.writer.port")),
+								Addr: fmt.Sprintf("%s:%d", conf.Redis.Writer.Host, conf.Redis.Writer.Port),
 							},
 							{
-								Addr: fmt.Sprintf("%s:%d", vip.GetString("redis.reader.host"), vip.GetUint16("redis.reader.port")),
+								Addr: fmt.Sprintf("%s:%d", conf.Redis.Reader.Host, conf.Redis.Reader.Port),
 							},
 						},
 					},
@@ -57,14 +58,14 @@ func NewRedisClient(ctx context.Context, vip *viper.Viper) (redis.UniversalClien
 
 		universalClient = c
 	default:
-		otelzap.L().Ctx(ctx).Fatal("redis mode not supported", zap.String("mode", vip.GetString("redis.mode")))
-		return nil, fmt.Errorf("redis mode %s not supported", vip.GetString("redis.mode"))
+		otelzap.L().Ctx(ctx).Fatal("redis mode not supported", zap.String("mode", conf.Redis.Mode))
+		return nil, fmt.Errorf("redis mode %s not supported", conf.Redis.Mode)
 	}
 
 	return universalClient, nil
 }
 
-func InjectRedisObservability(ctx context.Context, rdb redis.UniversalClient, vip *viper.Viper, prom *prometheus.Registry) (redis.UniversalClient, error) {
+func InjectRedisObservability(ctx context.Context, rdb redis.UniversalClient, conf *config.Config, prom *prometheus.Registry) (redis.UniversalClient, error) {
 	ctx, span := tracer.Start(ctx, "infra.InjectRedisObservability")
 	defer span.End()
 
@@ -83,8 +84,8 @@ func InjectRedisObservability(ctx context.Context, rdb redis.UniversalClient, vi
 	}
 
 	collector := redisprometheus.NewCollector(
-		vip.GetString("redis.metrics.namespace"),
-		vip.GetString("redis.metrics.subsystem"),
+		conf.Redis.Metrics.Namespace,
+		conf.Redis.Metrics.Subsystem,
 		rdb,
 	)
 	if err := prom.Register(collector); err != nil {
@@ -11,5 +11,6 @@ func Module() fx.Option {
 
 		fx.Provide(fx.Annotate(NewLogger, fx.ParamTags(``, ``, `group:"logging_options"`))),
 		fx.Invoke(UseLogger),
+		fx.Invoke(WatchLevel),
 	)
 }
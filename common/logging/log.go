@@ -4,12 +4,14 @@ import (
 	"context"
 	"github.com/samber/lo"
 
-	"github.com/spf13/viper"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 )
 
 var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/internal/logging")
@@ -18,13 +20,24 @@ type Logger struct {
 	fx.Out
 	OtelLogger *otelzap.Logger
 	ZapLogger  *zap.Logger
+	// Level changes the level of the loggers at runtime.
+	Level zap.AtomicLevel
 }
 
-func NewLogger(ctx context.Context, viper *viper.Viper, options []zap.Option) (Logger, error) {
+func NewLogger(ctx context.Context, conf *config.Config, options []zap.Option) (Logger, error) {
 	ctx, span := tracer.Start(ctx, "logging.NewLogger")
 	defer span.End()
 
-	zapLogger, err := lo.If(viper.GetBool("debug"), zap.NewDevelopment).Else(zap.NewProduction)(options...)
+	cfg := lo.If(conf.Debug, zap.NewDevelopmentConfig).Else(zap.NewProductionConfig)()
+	level, err := parseLevel(conf)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("failed to parse log level", zap.Error(err))
+		return Logger{}, err
+	}
+	cfg.Level.SetLevel(level)
+
+	zapLogger, err := cfg.Build(options...)
 	if err != nil {
 		span.RecordError(err)
 		otelzap.L().Ctx(ctx).Error("failed to create logger", zap.Error(err))
@@ -32,16 +45,38 @@ func NewLogger(ctx context.Context, viper *viper.Viper, options []zap.Option) (L
 	}
 
 	otelLogger := otelzap.New(zapLogger,
-		otelzap.WithCaller(viper.GetBool("log.caller")),
-		otelzap.WithTraceIDField(viper.GetBool("log.trace_id")),
+		otelzap.WithCaller(conf.Logging.Caller),
+		otelzap.WithTraceIDField(conf.Logging.TraceID),
 	)
 
 	return Logger{
 		OtelLogger: otelLogger,
 		ZapLogger:  zapLogger,
+		Level:      cfg.Level,
 	}, nil
 }
 
+// parseLevel returns `logging.level`, debug in debug mode and info otherwise when it is empty.
+func parseLevel(conf *config.Config) (zapcore.Level, error) {
+	if conf.Logging.Level != "" {
+		return zapcore.ParseLevel(conf.Logging.Level)
+	}
+
+	return lo.Ternary(conf.Debug, zapcore.DebugLevel, zapcore.InfoLevel), nil
+}
+
+// WatchLevel applies changes of `logging.level` to the running loggers.
+func WatchLevel(reloader *config.Reloader, level zap.AtomicLevel) {
+	reloader.Subscribe("logging", []string{"logging.level"}, func(ctx context.Context, event config.ChangeEvent) (func(), error) {
+		lvl, err := parseLevel(event.Config)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { level.SetLevel(lvl) }, nil
+	})
+}
+
 func UseLogger(zapLogger *zap.Logger, otelLogger *otelzap.Logger) {
 	zap.ReplaceGlobals(zapLogger)
 	otelzap.ReplaceGlobals(otelLogger)
@@ -3,20 +3,21 @@ package logging
 import (
 	"context"
 
-	"github.com/spf13/viper"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 )
 
-func callerOption(v *viper.Viper) zap.Option {
-	return zap.WithCaller(v.GetBool("logging.caller"))
+func callerOption(conf *config.Config) zap.Option {
+	return zap.WithCaller(conf.Logging.Caller)
 }
 
-func stacktraceOption(ctx context.Context, v *viper.Viper) (zap.Option, error) {
+func stacktraceOption(ctx context.Context, conf *config.Config) (zap.Option, error) {
 	ctx, span := tracer.Start(ctx, "logging.stacktraceOption")
 	defer span.End()
 
-	level, err := zap.ParseAtomicLevel(v.GetString("logging.stacktrace"))
+	level, err := zap.ParseAtomicLevel(conf.Logging.Stacktrace)
 	if err != nil {
 		otelzap.Ctx(ctx).Error("failed to parse stacktrace level", zap.Error(err))
 		return nil, err
@@ -7,6 +7,7 @@ func Module() fx.Option {
 		fx.Provide(fx.Annotate(NewResource, fx.ParamTags(``, `name:"serviceName"`))),
 
 		fx.Provide(NewTraceExporter),
+		fx.Provide(NewSampler),
 		fx.Provide(NewTraceProvider),
 		fx.Invoke(InitTraceProvider),
 
@@ -5,24 +5,25 @@ import (
 	"fmt"
 
 	promclient "github.com/prometheus/client_golang/prometheus"
-	"github.com/spf13/viper"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	metricsdk "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 )
 
-func NewMeterReader(ctx context.Context, vip *viper.Viper, registerer *promclient.Registry) (metricsdk.Reader, error) {
+func NewMeterReader(ctx context.Context, conf *config.Config, registerer *promclient.Registry) (metricsdk.Reader, error) {
 	ctx, span := tracer.Start(ctx, "observability.NewMeterReader")
 	defer span.End()
 
-	readerType := vip.GetString("observability.metric.reader.type")
+	readerType := conf.Observability.Metric.Reader.Type
 	switch readerType {
 	case "prometheus":
 		exporter, err := prometheus.New(
-			prometheus.WithNamespace(vip.GetString("namespace")),
+			prometheus.WithNamespace(conf.Namespace),
 			prometheus.WithRegisterer(registerer),
 		)
 		if err != nil {
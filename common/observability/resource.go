@@ -4,12 +4,13 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/spf13/viper"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 )
 
-func NewResource(ctx context.Context, serviceName string, vip *viper.Viper) (*resource.Resource, error) {
+func NewResource(ctx context.Context, serviceName string, conf *config.Config) (*resource.Resource, error) {
 	ctx, span := tracer.Start(ctx, "observability.NewResource")
 	defer span.End()
 
@@ -20,10 +21,10 @@ func NewResource(ctx context.Context, serviceName string, vip *viper.Viper) (*re
 
 		resource.WithSchemaURL(semconv.SchemaURL),
 		resource.WithAttributes(
-			semconv.ServiceNamespaceKey.String(vip.GetString("namespace")),
-			semconv.ServiceNameKey.String(fmt.Sprintf("%s-%s", vip.GetString("name"), serviceName)),
-			semconv.ServiceVersionKey.String(vip.GetString("version")),
-			semconv.ServiceInstanceIDKey.String(vip.GetString("instance_id")),
+			semconv.ServiceNamespaceKey.String(conf.Namespace),
+			semconv.ServiceNameKey.String(fmt.Sprintf("%s-%s", conf.Name, serviceName)),
+			semconv.ServiceVersionKey.String(conf.Version),
+			semconv.ServiceInstanceIDKey.String(conf.InstanceID),
 		),
 	)
 }
@@ -0,0 +1,60 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+// Sampler samples by trace id like tracesdk.TraceIDRatioBased, with a ratio that can be changed at runtime.
+type Sampler struct {
+	sampler atomic.Pointer[ratioSampler]
+}
+
+type ratioSampler struct {
+	ratio float64
+	tracesdk.Sampler
+}
+
+var _ tracesdk.Sampler = (*Sampler)(nil)
+
+func NewSampler(conf *config.Config, reloader *config.Reloader) (*Sampler, error) {
+	s := &Sampler{}
+	if err := s.SetRatio(conf.Observability.Trace.SamplingRate); err != nil {
+		return nil, err
+	}
+
+	reloader.Subscribe("trace sampler", []string{"observability.trace.sampling_rate"}, func(ctx context.Context, event config.ChangeEvent) (func(), error) {
+		ratio := event.Config.Observability.Trace.SamplingRate
+		return func() { _ = s.SetRatio(ratio) }, nil
+	})
+
+	return s, nil
+}
+
+// Ratio returns the fraction of the traces sampled.
+func (s *Sampler) Ratio() float64 {
+	return s.sampler.Load().ratio
+}
+
+// SetRatio samples the fraction ratio of the traces from now on, ratio must be between 0 and 1.
+func (s *Sampler) SetRatio(ratio float64) error {
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("sampling rate must be between 0 and 1, got %g", ratio)
+	}
+
+	s.sampler.Store(&ratioSampler{ratio: ratio, Sampler: tracesdk.TraceIDRatioBased(ratio)})
+	return nil
+}
+
+func (s *Sampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	return s.sampler.Load().ShouldSample(p)
+}
+
+func (s *Sampler) Description() string {
+	return s.sampler.Load().Description()
+}
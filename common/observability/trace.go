@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/spf13/viper"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
@@ -13,23 +12,25 @@ import (
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 )
 
 var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/internal/observability")
 
-func NewTraceExporter(ctx context.Context, vip *viper.Viper) (tracesdk.SpanExporter, error) {
+func NewTraceExporter(ctx context.Context, conf *config.Config) (tracesdk.SpanExporter, error) {
 	ctx, span := tracer.Start(ctx, "observability.NewTraceExporter")
 	defer span.End()
 
-	exporterType := vip.GetString("observability.trace.exporter.type")
+	exporterCfg := conf.Observability.Trace.Exporter
 
-	switch exporterType {
+	switch exporterCfg.Type {
 	case "otlp-grpc":
 		opts := []otlptracegrpc.Option{
-			otlptracegrpc.WithEndpoint(vip.GetString("observability.trace.exporter.endpoint")),
-			otlptracegrpc.WithTimeout(vip.GetDuration("observability.trace.exporter.timeout")),
+			otlptracegrpc.WithEndpoint(exporterCfg.Endpoint),
+			otlptracegrpc.WithTimeout(exporterCfg.Timeout),
 		}
-		if vip.GetBool("observability.trace.exporter.insecure") {
+		if exporterCfg.Insecure {
 			opts = append(opts, otlptracegrpc.WithInsecure())
 		}
 
@@ -43,10 +44,10 @@ func NewTraceExporter(ctx context.Context, vip *viper.Viper) (tracesdk.SpanExpor
 		return exporter, nil
 	case "otlp-http":
 		opts := []otlptracegrpc.Option{
-			otlptracegrpc.WithEndpoint(vip.GetString("observability.trace.exporter.endpoint")),
-			otlptracegrpc.WithTimeout(vip.GetDuration("observability.trace.exporter.timeout")),
+			otlptracegrpc.WithEndpoint(exporterCfg.Endpoint),
+			otlptracegrpc.WithTimeout(exporterCfg.Timeout),
 		}
-		if vip.GetBool("observability.trace.exporter.insecure") {
+		if exporterCfg.Insecure {
 			opts = append(opts, otlptracegrpc.WithInsecure())
 		}
 
@@ -59,17 +60,18 @@ func NewTraceExporter(ctx context.Context, vip *viper.Viper) (tracesdk.SpanExpor
 
 		return exporter, nil
 	default:
-		otelzap.L().Ctx(ctx).Fatal("unknown trace exporter type", zap.String("type", exporterType))
-		return nil, fmt.Errorf("unknown trace exporter type: %s", exporterType)
+		otelzap.L().Ctx(ctx).Fatal("unknown trace exporter type", zap.String("type", exporterCfg.Type))
+		return nil, fmt.Errorf("unknown trace exporter type: %s", exporterCfg.Type)
 	}
 }
 
 func NewTraceProvider(
 	ctx context.Context,
 	lc fx.Lifecycle,
-	vip *viper.Viper,
+	conf *config.Config,
 	resource *resource.Resource,
 	exporter tracesdk.SpanExporter,
+	sampler *Sampler,
 ) *tracesdk.TracerProvider {
 	ctx, span := tracer.Start(ctx, "observability.NewTraceProvider")
 	defer span.End()
@@ -78,12 +80,12 @@ func NewTraceProvider(
 		tracesdk.WithResource(resource),
 		tracesdk.WithBatcher(
 			exporter,
-			tracesdk.WithBatchTimeout(vip.GetDuration("observability.trace.batch_timeout")),
-			tracesdk.WithMaxExportBatchSize(vip.GetInt("observability.trace.max_batch_size")),
-			tracesdk.WithExportTimeout(vip.GetDuration("observability.trace.export_timeout")),
-			tracesdk.WithMaxQueueSize(vip.GetInt("observability.trace.max_queue_size")),
+			tracesdk.WithBatchTimeout(conf.Observability.Trace.BatchTimeout),
+			tracesdk.WithMaxExportBatchSize(conf.Observability.Trace.MaxBatchEntries),
+			tracesdk.WithExportTimeout(conf.Observability.Trace.ExportTimeout),
+			tracesdk.WithMaxQueueSize(conf.Observability.Trace.MaxQueueSize),
 		),
-		tracesdk.WithSampler(tracesdk.TraceIDRatioBased(vip.GetFloat64("observability.trace.sampling_rate"))),
+		tracesdk.WithSampler(sampler),
 	}
 
 	tp := tracesdk.NewTracerProvider(opts...)
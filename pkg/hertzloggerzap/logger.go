@@ -20,12 +20,16 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"io"
+	"sync/atomic"
 )
 
 var _ hlog.FullLogger = (*Logger)(nil)
 
 type Logger struct {
-	l      *zap.Logger
+	// l is swapped by SetLevel and SetOutput while other goroutines log
+	l atomic.Pointer[zap.Logger]
+	// base is the wrapped logger of NewLoggerWithZapLogger, SetLevel filters its core
+	base   *zap.Logger
 	config *config
 }
 
@@ -46,17 +50,17 @@ func NewLogger(opts ...Option) *Logger {
 		zapcore.NewTee(cores[:]...),
 		config.zapOpts...)
 
-	return &Logger{
-		l:      logger,
-		config: config,
-	}
+	l := &Logger{config: config}
+	l.l.Store(logger)
+
+	return l
 }
 
 func NewLoggerWithZapLogger(zapLogger *zap.Logger) *Logger {
-	return &Logger{
-		l:      zapLogger,
-		config: defaultConfig(),
-	}
+	l := &Logger{base: zapLogger, config: defaultConfig()}
+	l.l.Store(zapLogger)
+
+	return l
 }
 
 // GetExtraKeys get extraKeys from logger config
@@ -74,7 +78,7 @@ func (l *Logger) PutExtraKeys(keys ...ExtraKey) {
 }
 
 func (l *Logger) Log(level hlog.Level, kvs ...interface{}) {
-	sugar := l.l.Sugar()
+	sugar := l.l.Load().Sugar()
 	switch level {
 	case hlog.LevelTrace, hlog.LevelDebug:
 		sugar.Debug(kvs...)
@@ -92,7 +96,7 @@ func (l *Logger) Log(level hlog.Level, kvs ...interface{}) {
 }
 
 func (l *Logger) Logf(level hlog.Level, format string, kvs ...interface{}) {
-	logger := l.l.Sugar().With()
+	logger := l.l.Load().Sugar().With()
 	switch level {
 	case hlog.LevelTrace, hlog.LevelDebug:
 		logger.Debugf(format, kvs...)
@@ -110,7 +114,7 @@ func (l *Logger) Logf(level hlog.Level, format string, kvs ...interface{}) {
 }
 
 func (l *Logger) CtxLogf(level hlog.Level, ctx context.Context, format string, kvs ...interface{}) {
-	log := l.l.Sugar()
+	log := l.l.Load().Sugar()
 	if len(l.config.extraKeys) > 0 {
 		for _, k := range l.config.extraKeys {
 			if l.config.extraKeyAsStr {
@@ -237,6 +241,14 @@ func (l *Logger) SetLevel(level hlog.Level) {
 		lvl = zap.WarnLevel
 	}
 
+	if l.base != nil {
+		// the cores of a wrapped logger are unknown, filter them instead of rebuilding from the config
+		l.l.Store(l.base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &levelFilterCore{Core: core, level: lvl}
+		})))
+		return
+	}
+
 	l.config.coreConfigs[0].Lvl = lvl
 
 	cores := make([]zapcore.Core, 0, len(l.config.coreConfigs))
@@ -248,7 +260,7 @@ func (l *Logger) SetLevel(level hlog.Level) {
 		zapcore.NewTee(cores[:]...),
 		l.config.zapOpts...)
 
-	l.l = logger
+	l.l.Store(logger)
 }
 
 func (l *Logger) SetOutput(writer io.Writer) {
@@ -263,14 +275,14 @@ func (l *Logger) SetOutput(writer io.Writer) {
 		zapcore.NewTee(cores[:]...),
 		l.config.zapOpts...)
 
-	l.l = logger
+	l.l.Store(logger)
 }
 
 // Logger is used to return an instance of *zap.Logger for custom fields, etc.
 func (l *Logger) Logger() *zap.Logger {
-	return l.l
+	return l.l.Load()
 }
 
 func (l *Logger) Sync() {
-	_ = l.l.Sync()
+	_ = l.l.Load().Sync()
 }
@@ -199,6 +199,38 @@ func TestLogLevel(t *testing.T) {
 	assert.True(t, strings.Contains(buf.String(), "this is a error log"))
 }
 
+// TestZapLoggerLogLevel test SetLevel of a logger wrapping a zap logger
+func TestZapLoggerLogLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	zapLogger := zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(buf),
+		zapcore.DebugLevel,
+	), zap.Fields(zap.String("wrapped", "yes")))
+
+	logger := NewLoggerWithZapLogger(zapLogger)
+	defer logger.Sync()
+
+	logger.Debug("this is a debug log")
+	assert.True(t, strings.Contains(buf.String(), "this is a debug log"))
+
+	logger.SetLevel(hlog.LevelWarn)
+
+	logger.Info("this is a info log")
+	assert.False(t, strings.Contains(buf.String(), "this is a info log"))
+
+	logger.Warn("this is a warn log")
+	assert.True(t, strings.Contains(buf.String(), "this is a warn log"))
+	// the wrapped logger is kept, with its fields and output
+	assert.True(t, strings.Contains(buf.String(), `"wrapped":"yes"`))
+
+	logger.SetLevel(hlog.LevelDebug)
+
+	logger.Debug("this is another debug log")
+	assert.True(t, strings.Contains(buf.String(), "this is another debug log"))
+}
+
 func TestWithCoreEnc(t *testing.T) {
 	buf := new(bytes.Buffer)
 
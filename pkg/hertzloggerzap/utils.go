@@ -14,6 +14,8 @@
 
 package zap
 
+import "go.uber.org/zap/zapcore"
+
 // InArray check if a string in a slice
 func InArray(key ExtraKey, arr []ExtraKey) bool {
 	for _, k := range arr {
@@ -23,3 +25,25 @@ func InArray(key ExtraKey, arr []ExtraKey) bool {
 	}
 	return false
 }
+
+// levelFilterCore drops the entries below level, on top of the level of the core.
+type levelFilterCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+func (c *levelFilterCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.level && c.Core.Enabled(lvl)
+}
+
+func (c *levelFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelFilterCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelFilterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.level {
+		return ce
+	}
+
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,64 @@
+// Package imagehash implements perceptual hashes to recognize visually identical images
+// that differ in encoding, compression or size.
+package imagehash
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"math/bits"
+
+	"github.com/nfnt/resize"
+)
+
+// Hash is a 64 bit perceptual hash.
+type Hash uint64
+
+// DHash computes the difference hash of an image: the image is shrunk to 9x8 grayscale pixels
+// and every bit tells whether a pixel is brighter than its right neighbour.
+func DHash(img image.Image) Hash {
+	small := resize.Resize(9, 8, img, resize.Bilinear)
+
+	var hash Hash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luminance(small.At(x, y)) > luminance(small.At(x+1, y)) {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+func luminance(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+	return (299*r + 587*g + 114*b) / 1000
+}
+
+// Distance returns the hamming distance between two hashes, 0 means identical.
+func (h Hash) Distance(other Hash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+func (h Hash) String() string {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(h))
+	return hex.EncodeToString(b)
+}
+
+// ParseHash parses the 16 hex digits produced by Hash.String.
+func ParseHash(s string) (Hash, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 8 {
+		return 0, fmt.Errorf("invalid hash length %d, expected 8 bytes", len(b))
+	}
+
+	return Hash(binary.BigEndian.Uint64(b)), nil
+}
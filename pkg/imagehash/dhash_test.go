@@ -0,0 +1,61 @@
+package imagehash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/assert"
+)
+
+func gradient(w, h int, reverse bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / w)
+			if reverse {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{R: v, G: uint8(y * 255 / h), B: v, A: 255})
+		}
+	}
+
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	asserts := assert.New(t)
+
+	img := gradient(640, 640, false)
+	hash := DHash(img)
+
+	// resizing keeps the hash (almost) identical
+	asserts.LessOrEqual(hash.Distance(DHash(resize.Resize(100, 100, img, resize.Lanczos3))), 2)
+
+	// a different picture is far away
+	asserts.Greater(hash.Distance(DHash(gradient(640, 640, true))), 32)
+}
+
+func TestHash_Distance(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.Equal(0, Hash(0xff).Distance(0xff))
+	asserts.Equal(8, Hash(0xff).Distance(0))
+	asserts.Equal(64, Hash(0).Distance(^Hash(0)))
+}
+
+func TestParseHash(t *testing.T) {
+	asserts := assert.New(t)
+
+	hash, err := ParseHash("00ff00ff00ff00ff")
+	asserts.NoError(err)
+	asserts.Equal(Hash(0x00ff00ff00ff00ff), hash)
+	asserts.Equal("00ff00ff00ff00ff", hash.String())
+
+	_, err = ParseHash("00ff")
+	asserts.Error(err)
+
+	_, err = ParseHash("zz")
+	asserts.Error(err)
+}
@@ -0,0 +1,42 @@
+package imaging
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// ParseColor parses hex colors in the rgb, rgba, rrggbb and rrggbbaa forms, with or without a leading `#`.
+func ParseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 || len(s) == 4 {
+		var expanded strings.Builder
+		for _, c := range s {
+			expanded.WriteRune(c)
+			expanded.WriteRune(c)
+		}
+		s = expanded.String()
+	}
+
+	if len(s) != 6 && len(s) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: %w", s, err)
+	}
+
+	c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+
+	return c, nil
+}
+
+// FormatColor returns the rrggbbaa form of the color.
+func FormatColor(c color.NRGBA) string {
+	return hex.EncodeToString([]byte{c.R, c.G, c.B, c.A})
+}
@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+// Layout arranges the members of a montage.
+type Layout string
+
+const (
+	// LayoutGrid puts the members in rows of ceil(sqrt(n)) squares.
+	LayoutGrid Layout = "grid"
+	// LayoutStack overlaps circles of the members from left to right, the first one on top.
+	LayoutStack Layout = "stack"
+	// LayoutGroup is the square group avatar of chat apps: one member fills the tile,
+	// two split it vertically, three have the first one on the left half and four make a 2x2 grid.
+	LayoutGroup Layout = "group"
+)
+
+// GroupMembers is the most members a LayoutGroup tile shows.
+const GroupMembers = 4
+
+// ParseLayout returns the layout of the name, one of grid, stack and group.
+func ParseLayout(name string) (Layout, error) {
+	switch layout := Layout(name); layout {
+	case LayoutGrid, LayoutStack, LayoutGroup:
+		return layout, nil
+	default:
+		return "", fmt.Errorf("unknown montage layout %q", name)
+	}
+}
+
+// MontageCells returns the canvas and the cell of every member of a montage of n members, width pixels wide.
+func MontageCells(layout Layout, n, width int) (image.Rectangle, []image.Rectangle) {
+	if n <= 0 || width <= 0 {
+		return image.Rectangle{}, nil
+	}
+
+	gap := width / 40
+	cells := make([]image.Rectangle, 0, n)
+
+	switch layout {
+	case LayoutStack:
+		// circles overlap by a third of their diameter and fill the width together
+		diameter := 3 * width / (3 + 2*(n-1))
+		step := float64(width-diameter) / math.Max(float64(n-1), 1)
+		for i := 0; i < n; i++ {
+			x := int(math.Round(float64(i) * step))
+			cells = append(cells, image.Rect(x, 0, x+diameter, diameter))
+		}
+
+		return image.Rect(0, 0, width, diameter), cells
+	case LayoutGroup:
+		half := (width - gap) / 2
+		left, right := image.Rect(0, 0, half, width), image.Rect(width-half, 0, width, width)
+		topRight, bottomRight := image.Rect(width-half, 0, width, half), image.Rect(width-half, width-half, width, width)
+		topLeft, bottomLeft := image.Rect(0, 0, half, half), image.Rect(0, width-half, half, width)
+
+		switch min(n, GroupMembers) {
+		case 1:
+			cells = append(cells, image.Rect(0, 0, width, width))
+		case 2:
+			cells = append(cells, left, right)
+		case 3:
+			cells = append(cells, left, topRight, bottomRight)
+		default:
+			cells = append(cells, topLeft, topRight, bottomLeft, bottomRight)
+		}
+
+		return image.Rect(0, 0, width, width), cells
+	default:
+		cols := int(math.Ceil(math.Sqrt(float64(n))))
+		rows := (n + cols - 1) / cols
+		cell := max((width-gap*(cols-1))/cols, 1)
+		for i := 0; i < n; i++ {
+			x, y := (i%cols)*(cell+gap), (i/cols)*(cell+gap)
+			cells = append(cells, image.Rect(x, y, x+cell, y+cell))
+		}
+
+		return image.Rect(0, 0, width, rows*cell+(rows-1)*gap), cells
+	}
+}
+
+// Montage draws the images into the cells of the layout, every image scaled and center cropped to cover its cell.
+func Montage(layout Layout, images []image.Image, width int, border color.NRGBA) image.Image {
+	canvas, cells := MontageCells(layout, len(images), width)
+	return Compose(layout, canvas, cells, images, border)
+}
+
+// Compose draws the images into cells computed by MontageCells beforehand, images already
+// in the size of their cell are only cropped.
+func Compose(layout Layout, canvas image.Rectangle, cells []image.Rectangle, images []image.Image, border color.NRGBA) image.Image {
+	dst := image.NewNRGBA(canvas)
+
+	// later members are drawn first, so the first one ends up on top of a stack
+	for i := len(cells) - 1; i >= 0; i-- {
+		img := cover(images[i], cells[i].Dx(), cells[i].Dy())
+		if layout == LayoutStack {
+			img = Shape(img, ShapeOptions{
+				Circle:      true,
+				BorderWidth: max(cells[i].Dx()/20, 1),
+				BorderColor: border,
+			})
+		}
+
+		draw.Draw(dst, cells[i], img, img.Bounds().Min, draw.Over)
+	}
+
+	return dst
+}
+
+// cover scales the image to cover width x height and crops the center.
+func cover(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	scale := math.Max(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	w := max(int(math.Ceil(float64(bounds.Dx())*scale)), width)
+	h := max(int(math.Ceil(float64(bounds.Dy())*scale)), height)
+	if w != bounds.Dx() || h != bounds.Dy() {
+		img = resize.Resize(uint(w), uint(h), img, resize.Lanczos3)
+		bounds = img.Bounds()
+	}
+
+	x, y := bounds.Min.X+(bounds.Dx()-width)/2, bounds.Min.Y+(bounds.Dy()-height)/2
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x, y), draw.Src)
+
+	return dst
+}
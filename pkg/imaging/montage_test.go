@@ -0,0 +1,73 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLayout(t *testing.T) {
+	asserts := assert.New(t)
+
+	layout, err := ParseLayout("group")
+	asserts.NoError(err)
+	asserts.Equal(LayoutGroup, layout)
+
+	_, err = ParseLayout("spiral")
+	asserts.Error(err)
+}
+
+func TestMontageCells(t *testing.T) {
+	asserts := assert.New(t)
+
+	canvas, cells := MontageCells(LayoutGrid, 4, 80)
+	asserts.Len(cells, 4)
+	asserts.Equal(image.Rect(0, 0, 80, 80), canvas)
+	asserts.Equal(image.Rect(0, 0, 39, 39), cells[0])
+	asserts.Equal(image.Rect(41, 0, 80, 39), cells[1])
+	asserts.Equal(image.Rect(0, 41, 39, 80), cells[2])
+
+	// the last row is not filled
+	canvas, cells = MontageCells(LayoutGrid, 5, 80)
+	asserts.Len(cells, 5)
+	asserts.Equal(image.Rect(0, 0, 80, 52), canvas)
+	asserts.Equal(image.Rect(27, 27, 52, 52), cells[4])
+
+	canvas, cells = MontageCells(LayoutStack, 4, 90)
+	asserts.Len(cells, 4)
+	asserts.Equal(image.Rect(0, 0, 90, 30), canvas)
+	asserts.Equal(0, cells[0].Min.X)
+	asserts.Equal(90, cells[3].Max.X)
+
+	_, cells = MontageCells(LayoutGroup, 3, 80)
+	asserts.Equal([]image.Rectangle{image.Rect(0, 0, 39, 80), image.Rect(41, 0, 80, 39), image.Rect(41, 41, 80, 80)}, cells)
+
+	_, cells = MontageCells(LayoutGroup, 9, 80)
+	asserts.Len(cells, GroupMembers)
+
+	_, cells = MontageCells(LayoutGrid, 0, 80)
+	asserts.Empty(cells)
+}
+
+func TestMontage(t *testing.T) {
+	asserts := assert.New(t)
+
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	group := Montage(LayoutGroup, []image.Image{solid(100, 100, red), solid(50, 100, blue)}, 80, white)
+	asserts.Equal(image.Rect(0, 0, 80, 80), group.Bounds())
+	asserts.Equal(red, group.At(20, 40))
+	asserts.Equal(blue, group.At(60, 40))
+	// the gap stays transparent
+	asserts.Equal(color.NRGBA{}, group.At(40, 40))
+
+	stack := Montage(LayoutStack, []image.Image{solid(100, 100, red), solid(100, 100, blue)}, 50, white)
+	asserts.Equal(image.Rect(0, 0, 50, 30), stack.Bounds())
+	// the first member is on top where the circles overlap
+	asserts.Equal(red, stack.At(25, 15))
+	asserts.Equal(blue, stack.At(40, 15))
+}
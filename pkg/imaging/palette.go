@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// AverageColor returns the mean color of the image, weighted by the alpha of every pixel.
+func AverageColor(img image.Image) color.NRGBA {
+	bounds := img.Bounds()
+
+	var r, g, b, a uint64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pr, pg, pb, pa := img.At(x, y).RGBA()
+			r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+		}
+	}
+
+	if a == 0 {
+		return color.NRGBA{}
+	}
+
+	// the channels are premultiplied, so dividing by the summed alpha weights them
+	return color.NRGBA{
+		R: uint8(r * 0xff / a),
+		G: uint8(g * 0xff / a),
+		B: uint8(b * 0xff / a),
+		A: 0xff,
+	}
+}
+
+// DominantColor returns the most frequent color of the image, colors are grouped into 4096 buckets
+// of 4 bits per channel and the mean of the largest bucket is returned. Mostly transparent pixels are ignored.
+func DominantColor(img image.Image) color.NRGBA {
+	bounds := img.Bounds()
+
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	var buckets [4096]bucket
+
+	best := -1
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 0x80 {
+				continue
+			}
+
+			i := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+			buckets[i].count++
+			buckets[i].r += int(c.R)
+			buckets[i].g += int(c.G)
+			buckets[i].b += int(c.B)
+
+			if best < 0 || buckets[i].count > buckets[best].count {
+				best = i
+			}
+		}
+	}
+
+	if best < 0 {
+		return color.NRGBA{}
+	}
+
+	bk := buckets[best]
+	return color.NRGBA{
+		R: uint8(bk.r / bk.count),
+		G: uint8(bk.g / bk.count),
+		B: uint8(bk.b / bk.count),
+		A: 0xff,
+	}
+}
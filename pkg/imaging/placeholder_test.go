@@ -0,0 +1,58 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlurHash(t *testing.T) {
+	asserts := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 8), G: uint8(y * 10), B: uint8((x * y) % 256), A: 0xff})
+		}
+	}
+
+	hash, err := BlurHash(img, 4, 3)
+	asserts.NoError(err)
+	asserts.Equal("LxH27h2lwtX3mAWUjwfAgFfmfTfi", hash)
+
+	hash, err = BlurHash(img, 1, 1)
+	asserts.NoError(err)
+	asserts.Equal("00H27h", hash)
+
+	_, err = BlurHash(img, 0, 10)
+	asserts.Error(err)
+
+	_, err = BlurHash(image.NewNRGBA(image.Rect(0, 0, 0, 0)), 4, 3)
+	asserts.Error(err)
+}
+
+func TestAverageColor(t *testing.T) {
+	asserts := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{B: 0xff, A: 0xff})
+	// transparent pixels do not count
+	img.SetNRGBA(0, 1, color.NRGBA{G: 0xff})
+
+	asserts.Equal(color.NRGBA{R: 0x7f, B: 0x7f, A: 0xff}, AverageColor(img))
+	asserts.Equal(color.NRGBA{}, AverageColor(image.NewNRGBA(image.Rect(0, 0, 2, 2))))
+}
+
+func TestDominantColor(t *testing.T) {
+	asserts := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xf0, A: 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 0xf2, A: 0xff})
+	img.SetNRGBA(2, 0, color.NRGBA{B: 0xff, A: 0xff})
+
+	asserts.Equal(color.NRGBA{R: 0xf1, A: 0xff}, DominantColor(img))
+}
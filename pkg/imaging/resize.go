@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+var filters = map[string]resize.InterpolationFunction{
+	"nearest":  resize.NearestNeighbor,
+	"bilinear": resize.Bilinear,
+	"bicubic":  resize.Bicubic,
+	"mitchell": resize.MitchellNetravali,
+	"lanczos2": resize.Lanczos2,
+	"lanczos3": resize.Lanczos3,
+}
+
+// ParseFilter returns the resampling filter of the name, one of nearest, bilinear, bicubic,
+// mitchell, lanczos2 and lanczos3.
+func ParseFilter(name string) (resize.InterpolationFunction, error) {
+	filter, ok := filters[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown resize filter %q", name)
+	}
+
+	return filter, nil
+}
+
+// Resize scales the image to width x height. With linear set, the pixels are converted from sRGB
+// to linear light before resampling and back afterwards, so downscaling doesn't darken fine detail.
+func Resize(img image.Image, width, height uint, filter resize.InterpolationFunction, linear bool) image.Image {
+	if !linear {
+		return resize.Resize(width, height, img, filter)
+	}
+
+	return toSRGB(resize.Resize(width, height, toLinear(img), filter))
+}
+
+var srgbToLinear = func() (lut [256]uint16) {
+	for i := range lut {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		lut[i] = uint16(math.Round(v * 0xffff))
+	}
+
+	return lut
+}()
+
+func linearToSRGB(v float64) uint8 {
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
+
+// toLinear converts to 16 bit premultiplied linear light, which the resampler works on directly.
+func toLinear(img image.Image) *image.RGBA64 {
+	bounds := img.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			a := uint32(c.A) * 0x101
+			dst.SetRGBA64(x-bounds.Min.X, y-bounds.Min.Y, color.RGBA64{
+				R: uint16(uint32(srgbToLinear[c.R]) * a / 0xffff),
+				G: uint16(uint32(srgbToLinear[c.G]) * a / 0xffff),
+				B: uint16(uint32(srgbToLinear[c.B]) * a / 0xffff),
+				A: uint16(a),
+			})
+		}
+	}
+
+	return dst
+}
+
+func toSRGB(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+
+			alpha := float64(a)
+			dst.SetNRGBA(x-bounds.Min.X, y-bounds.Min.Y, color.NRGBA{
+				R: linearToSRGB(float64(r) / alpha),
+				G: linearToSRGB(float64(g) / alpha),
+				B: linearToSRGB(float64(b) / alpha),
+				A: uint8(a >> 8),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,56 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkerboard alternates black and white pixels, which averages to 50% gray in linear light.
+func checkerboard(size int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if (x+y)%2 == 0 {
+				img.SetNRGBA(x, y, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{A: 0xff})
+			}
+		}
+	}
+
+	return img
+}
+
+func TestResize(t *testing.T) {
+	asserts := assert.New(t)
+
+	src := checkerboard(64)
+
+	gamma := color.NRGBAModel.Convert(Resize(src, 8, 8, resize.Bilinear, false).At(4, 4)).(color.NRGBA)
+	linear := color.NRGBAModel.Convert(Resize(src, 8, 8, resize.Bilinear, true).At(4, 4)).(color.NRGBA)
+
+	// averaging in sRGB gives ~128, the correct linear average is ~188 in sRGB
+	asserts.InDelta(128, int(gamma.R), 4)
+	asserts.InDelta(188, int(linear.R), 4)
+	asserts.Equal(uint8(0xff), linear.A)
+
+	// transparency survives the round trip
+	transparent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	out := color.NRGBAModel.Convert(Resize(transparent, 2, 2, resize.Lanczos3, true).At(1, 1)).(color.NRGBA)
+	asserts.Equal(uint8(0), out.A)
+}
+
+func TestParseFilter(t *testing.T) {
+	asserts := assert.New(t)
+
+	filter, err := ParseFilter("mitchell")
+	asserts.NoError(err)
+	asserts.Equal(resize.MitchellNetravali, filter)
+
+	_, err = ParseFilter("unknown")
+	asserts.Error(err)
+}
@@ -0,0 +1,145 @@
+// Package imaging post-processes avatars before they are encoded.
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+// ShapeOptions describes the mask, border and background drawn around an avatar.
+// The zero value leaves the image untouched.
+type ShapeOptions struct {
+	// Circle masks the image with a circle, it takes precedence over Radius.
+	Circle bool
+	// Radius rounds the corners, in pixels.
+	Radius int
+	// Padding shrinks the image inside the canvas, in pixels per side.
+	Padding int
+	// BorderWidth draws a border along the inside of the shape, in pixels.
+	BorderWidth int
+	BorderColor color.NRGBA
+	// Background fills the transparent parts of the canvas, nil keeps them transparent.
+	Background *color.NRGBA
+}
+
+func (o ShapeOptions) IsZero() bool {
+	return !o.Circle && o.Radius <= 0 && o.Padding <= 0 && o.BorderWidth <= 0 && o.Background == nil
+}
+
+// Scale converts the options to an image of f times the size they were given for.
+func (o ShapeOptions) Scale(f float64) ShapeOptions {
+	o.Radius = int(math.Round(float64(o.Radius) * f))
+	o.Padding = int(math.Round(float64(o.Padding) * f))
+	o.BorderWidth = int(math.Round(float64(o.BorderWidth) * f))
+	return o
+}
+
+// Shape applies the options, the result keeps the size of the source image. Padding, radius and
+// border are clamped to what fits the image, so options larger than it never fail.
+// Edges are anti-aliased from the distance of every pixel center to the shape outline.
+func Shape(img image.Image, opts ShapeOptions) image.Image {
+	if opts.IsZero() {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	padding := min(max(opts.Padding, 0), (min(w, h)-1)/2)
+	cw, ch := w-2*padding, h-2*padding
+
+	src := img
+	if padding > 0 {
+		src = resize.Resize(uint(cw), uint(ch), img, resize.Lanczos3)
+	}
+	srcBounds := src.Bounds()
+
+	radius := float64(max(opts.Radius, 0))
+	if opts.Circle {
+		radius = float64(min(cw, ch)) / 2
+	}
+	radius = math.Min(radius, float64(min(cw, ch))/2)
+	borderWidth := math.Min(float64(max(opts.BorderWidth, 0)), float64(min(cw, ch))/2)
+
+	halfW, halfH := float64(cw)/2, float64(ch)/2
+	centerX, centerY := float64(padding)+halfW, float64(padding)+halfH
+
+	border := premultiply(opts.BorderColor)
+	var background [4]float64
+	if opts.Background != nil {
+		background = premultiply(*opts.Background)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			d := roundedRectDistance(float64(x)+0.5-centerX, float64(y)+0.5-centerY, halfW, halfH, radius)
+			outer := coverage(d)
+			inner := outer
+			if borderWidth > 0 {
+				inner = coverage(d + borderWidth)
+			}
+
+			px := background
+			if outer > inner {
+				px = over(px, scale(border, outer-inner))
+			}
+			if inner > 0 {
+				sx, sy := x-padding, y-padding
+				if sx >= 0 && sy >= 0 && sx < cw && sy < ch {
+					px = over(px, scale(premultiply(color.NRGBAModel.Convert(src.At(srcBounds.Min.X+sx, srcBounds.Min.Y+sy)).(color.NRGBA)), inner))
+				}
+			}
+
+			dst.SetNRGBA(x, y, unpremultiply(px))
+		}
+	}
+
+	return dst
+}
+
+// roundedRectDistance is the signed distance from a point, relative to the center,
+// to a rectangle of the half sizes with rounded corners, negative inside.
+func roundedRectDistance(px, py, halfW, halfH, radius float64) float64 {
+	qx := math.Abs(px) - (halfW - radius)
+	qy := math.Abs(py) - (halfH - radius)
+
+	outside := math.Hypot(math.Max(qx, 0), math.Max(qy, 0))
+	inside := math.Min(math.Max(qx, qy), 0)
+
+	return outside + inside - radius
+}
+
+func coverage(distance float64) float64 {
+	return math.Max(0, math.Min(1, 0.5-distance))
+}
+
+func premultiply(c color.NRGBA) [4]float64 {
+	a := float64(c.A) / 255
+	return [4]float64{float64(c.R) / 255 * a, float64(c.G) / 255 * a, float64(c.B) / 255 * a, a}
+}
+
+func unpremultiply(c [4]float64) color.NRGBA {
+	if c[3] <= 0 {
+		return color.NRGBA{}
+	}
+
+	channel := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+
+	return color.NRGBA{R: channel(c[0] / c[3]), G: channel(c[1] / c[3]), B: channel(c[2] / c[3]), A: channel(c[3])}
+}
+
+func scale(c [4]float64, f float64) [4]float64 {
+	return [4]float64{c[0] * f, c[1] * f, c[2] * f, c[3] * f}
+}
+
+// over composites the premultiplied src over dst.
+func over(dst, src [4]float64) [4]float64 {
+	k := 1 - src[3]
+	return [4]float64{src[0] + dst[0]*k, src[1] + dst[1]*k, src[2] + dst[2]*k, src[3] + dst[3]*k}
+}
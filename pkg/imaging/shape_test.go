@@ -0,0 +1,85 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestShape(t *testing.T) {
+	asserts := assert.New(t)
+
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	src := solid(100, 100, red)
+
+	// the zero value is a no-op
+	asserts.Equal(src, Shape(src, ShapeOptions{}))
+
+	circle := Shape(src, ShapeOptions{Circle: true})
+	asserts.Equal(src.Bounds(), circle.Bounds())
+	asserts.Equal(red, circle.At(50, 50))
+	asserts.Equal(color.NRGBA{}, circle.At(0, 0))
+	// the edge is anti-aliased
+	_, _, _, a := circle.At(50, 0).RGBA()
+	asserts.Greater(a, uint32(0))
+	asserts.Less(a, uint32(0xffff))
+
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	bordered := Shape(src, ShapeOptions{Circle: true, BorderWidth: 5, BorderColor: white, Background: &white})
+	asserts.Equal(white, bordered.At(0, 0))
+	asserts.Equal(white, bordered.At(50, 2))
+	asserts.Equal(red, bordered.At(50, 50))
+
+	rounded := Shape(src, ShapeOptions{Radius: 20})
+	asserts.Equal(color.NRGBA{}, rounded.At(0, 0))
+	asserts.Equal(red, rounded.At(50, 0))
+
+	padded := Shape(src, ShapeOptions{Padding: 10})
+	asserts.Equal(src.Bounds(), padded.Bounds())
+	asserts.Equal(color.NRGBA{}, padded.At(5, 50))
+	asserts.Equal(red, padded.At(50, 50))
+
+	// options larger than the image are clamped
+	small := solid(10, 10, red)
+	clamped := Shape(small, ShapeOptions{Padding: 100, Radius: 100, BorderWidth: 100, BorderColor: white})
+	asserts.Equal(small.Bounds(), clamped.Bounds())
+	asserts.Equal(color.NRGBA{}, clamped.At(0, 0))
+}
+
+func TestShapeOptions_Scale(t *testing.T) {
+	asserts := assert.New(t)
+
+	opts := ShapeOptions{Circle: true, Radius: 20, Padding: 10, BorderWidth: 5}
+	asserts.Equal(ShapeOptions{Circle: true, Radius: 8, Padding: 4, BorderWidth: 2}, opts.Scale(0.4))
+}
+
+func TestParseColor(t *testing.T) {
+	asserts := assert.New(t)
+
+	c, err := ParseColor("#ff8000")
+	asserts.NoError(err)
+	asserts.Equal(color.NRGBA{R: 0xff, G: 0x80, A: 0xff}, c)
+
+	c, err = ParseColor("f008")
+	asserts.NoError(err)
+	asserts.Equal(color.NRGBA{R: 0xff, A: 0x88}, c)
+	asserts.Equal("ff000088", FormatColor(c))
+
+	_, err = ParseColor("ff80")
+	asserts.NoError(err)
+
+	_, err = ParseColor("zzzzzz")
+	asserts.Error(err)
+
+	_, err = ParseColor("12345")
+	asserts.Error(err)
+}
@@ -0,0 +1,98 @@
+// Package ratelimit provides an in-memory GCRA limiter that mirrors the
+// semantics of github.com/go-redis/redis_rate, so it can stand in for the
+// redis backed limiter when redis is unavailable.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis_rate/v10"
+)
+
+type Limiter struct {
+	mu      sync.Mutex
+	entries map[string]time.Time // key -> theoretical arrival time
+	now     func() time.Time
+
+	lastSweep time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Allow is a shortcut for AllowN(ctx, key, limit, 1).
+func (l *Limiter) Allow(ctx context.Context, key string, limit redis_rate.Limit) (*redis_rate.Result, error) {
+	return l.AllowN(ctx, key, limit, 1)
+}
+
+// AllowN reports whether n events may happen at time now.
+func (l *Limiter) AllowN(_ context.Context, key string, limit redis_rate.Limit, n int) (*redis_rate.Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	emissionInterval := limit.Period / time.Duration(limit.Rate)
+	burstOffset := emissionInterval * time.Duration(limit.Burst)
+
+	tat, ok := l.entries[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(emissionInterval * time.Duration(n))
+	allowAt := newTat.Add(-burstOffset)
+	diff := now.Sub(allowAt)
+
+	// compared before truncating, a request arriving a moment after the bucket emptied is still over the limit
+	if diff < 0 {
+		return &redis_rate.Result{
+			Limit:      limit,
+			Allowed:    0,
+			Remaining:  0,
+			RetryAfter: -diff,
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+
+	l.entries[key] = newTat
+
+	return &redis_rate.Result{
+		Limit:      limit,
+		Allowed:    n,
+		Remaining:  int(diff / emissionInterval),
+		RetryAfter: -1,
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+// Reset gets a key and reset all limitations and previous usages
+func (l *Limiter) Reset(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+	return nil
+}
+
+// sweep drops the entries whose theoretical arrival time is already in the past,
+// they behave exactly like absent keys. It runs at most once per minute.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	l.lastSweep = now
+	for key, tat := range l.entries {
+		if tat.Before(now) {
+			delete(l.entries, key)
+		}
+	}
+}
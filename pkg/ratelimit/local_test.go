@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis_rate/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := NewLimiter()
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	asserts := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(&now)
+	limit := redis_rate.PerSecond(10)
+
+	res, err := l.Allow(context.Background(), "test", limit)
+	asserts.NoError(err)
+	asserts.Equal(1, res.Allowed)
+	asserts.Equal(9, res.Remaining)
+	asserts.Equal(time.Duration(-1), res.RetryAfter)
+	asserts.Equal(100*time.Millisecond, res.ResetAfter)
+}
+
+func TestLimiter_AllowBurstExhausted(t *testing.T) {
+	asserts := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(&now)
+	limit := redis_rate.Limit{Rate: 2, Burst: 2, Period: time.Second}
+
+	for i := 0; i < 2; i++ {
+		res, err := l.Allow(context.Background(), "test", limit)
+		asserts.NoError(err)
+		asserts.Equal(1, res.Allowed)
+	}
+
+	res, err := l.Allow(context.Background(), "test", limit)
+	asserts.NoError(err)
+	asserts.Equal(0, res.Allowed)
+	asserts.Equal(0, res.Remaining)
+	asserts.Equal(500*time.Millisecond, res.RetryAfter)
+	asserts.Equal(time.Second, res.ResetAfter)
+
+	// other keys are not affected
+	res, err = l.Allow(context.Background(), "other", limit)
+	asserts.NoError(err)
+	asserts.Equal(1, res.Allowed)
+
+	// tokens are refilled over time
+	now = now.Add(500 * time.Millisecond)
+	res, err = l.Allow(context.Background(), "test", limit)
+	asserts.NoError(err)
+	asserts.Equal(1, res.Allowed)
+	asserts.Equal(0, res.Remaining)
+
+	// a moment before the next token is due is still too early
+	now = now.Add(499 * time.Millisecond)
+	res, err = l.Allow(context.Background(), "test", limit)
+	asserts.NoError(err)
+	asserts.Equal(0, res.Allowed)
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	asserts := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(&now)
+	limit := redis_rate.Limit{Rate: 1, Burst: 1, Period: time.Minute}
+
+	res, err := l.Allow(context.Background(), "test", limit)
+	asserts.NoError(err)
+	asserts.Equal(1, res.Allowed)
+
+	res, err = l.Allow(context.Background(), "test", limit)
+	asserts.NoError(err)
+	asserts.Equal(0, res.Allowed)
+
+	asserts.NoError(l.Reset(context.Background(), "test"))
+
+	res, err = l.Allow(context.Background(), "test", limit)
+	asserts.NoError(err)
+	asserts.Equal(1, res.Allowed)
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	asserts := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(&now)
+
+	_, err := l.Allow(context.Background(), "test", redis_rate.PerSecond(1))
+	asserts.NoError(err)
+	asserts.Len(l.entries, 1)
+
+	now = now.Add(2 * time.Minute)
+	_, err = l.Allow(context.Background(), "other", redis_rate.PerSecond(1))
+	asserts.NoError(err)
+	asserts.Len(l.entries, 1)
+	asserts.Contains(l.entries, "other")
+}
@@ -0,0 +1,77 @@
+package urlsign
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner(t *testing.T) {
+	asserts := assert.New(t)
+
+	s, err := NewSigner(map[string]string{"2024": "old-secret", "2025": "new-secret"})
+	asserts.NoError(err)
+
+	query, err := s.Sign("2025", "/avatar/abc", url.Values{"s": {"80"}, "d": {"identicon"}}, time.Time{})
+	asserts.NoError(err)
+	asserts.Equal("2025", query.Get(ParamKeyID))
+	asserts.NoError(s.Verify("/avatar/abc", query))
+
+	// the order of the query does not matter
+	reordered, err := url.ParseQuery("sig=" + query.Get(ParamSignature) + "&s=80&kid=2025&d=identicon")
+	asserts.NoError(err)
+	asserts.NoError(s.Verify("/avatar/abc", reordered))
+
+	// keys are still accepted after rotation
+	old, err := s.Sign("2024", "/avatar/abc", nil, time.Time{})
+	asserts.NoError(err)
+	asserts.NoError(s.Verify("/avatar/abc", old))
+
+	// tampering
+	asserts.ErrorIs(s.Verify("/avatar/abd", query), ErrInvalidSignature)
+	tampered, _ := url.ParseQuery(query.Encode())
+	tampered.Set("s", "640")
+	asserts.ErrorIs(s.Verify("/avatar/abc", tampered), ErrInvalidSignature)
+
+	asserts.ErrorIs(s.Verify("/avatar/abc", url.Values{"s": {"80"}}), ErrMissingSignature)
+
+	unknown, _ := url.ParseQuery(query.Encode())
+	unknown.Set(ParamKeyID, "2023")
+	asserts.ErrorIs(s.Verify("/avatar/abc", unknown), ErrUnknownKey)
+
+	_, err = s.Sign("2023", "/avatar/abc", nil, time.Time{})
+	asserts.ErrorIs(err, ErrUnknownKey)
+}
+
+func TestSigner_Expiry(t *testing.T) {
+	asserts := assert.New(t)
+
+	s, err := NewSigner(map[string]string{"k": "secret"})
+	asserts.NoError(err)
+
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	query, err := s.Sign("k", "/avatar/abc", nil, now.Add(time.Minute))
+	asserts.NoError(err)
+	asserts.NoError(s.Verify("/avatar/abc", query))
+
+	now = now.Add(time.Minute)
+	asserts.ErrorIs(s.Verify("/avatar/abc", query), ErrExpired)
+
+	// the expiry is part of the signature
+	query.Set(ParamExpires, "1800000000")
+	asserts.ErrorIs(s.Verify("/avatar/abc", query), ErrInvalidSignature)
+}
+
+func TestNewSigner(t *testing.T) {
+	asserts := assert.New(t)
+
+	_, err := NewSigner(map[string]string{"k": ""})
+	asserts.Error(err)
+
+	_, err = NewSigner(nil)
+	asserts.Error(err)
+}
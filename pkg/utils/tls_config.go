@@ -18,7 +18,7 @@ func NewTLSConfig(ctx context.Context, params TLSParams) (*tls.Config, error) {
 	ctx, span := tracer.Start(ctx, "utils.NewTLSConfig")
 	defer span.End()
 
-	if params.CACertPath == "" && params.ClientCertPath == "" && params.ClientKeyPath == "" {
+	if params.CACertPath == "" && params.ClientCertPath == "" && params.ClientKeyPath == "" && !params.InsecureSkipVerify {
 		return nil, nil
 	}
 
@@ -42,7 +42,7 @@ func NewTLSConfig(ctx context.Context, params TLSParams) (*tls.Config, error) {
 		caPool = pool
 	}
 
-	var clientCert tls.Certificate
+	var clientCerts []tls.Certificate
 	if params.ClientCertPath != "" && params.ClientKeyPath != "" {
 		cert, err := tls.LoadX509KeyPair(params.ClientCertPath, params.ClientKeyPath)
 		if err != nil {
@@ -50,12 +50,12 @@ func NewTLSConfig(ctx context.Context, params TLSParams) (*tls.Config, error) {
 			return nil, err
 		}
 
-		clientCert = cert
+		clientCerts = append(clientCerts, cert)
 	}
 
 	return &tls.Config{
 		RootCAs:            caPool,
-		Certificates:       []tls.Certificate{clientCert},
+		Certificates:       clientCerts,
 		InsecureSkipVerify: params.InsecureSkipVerify,
 	}, nil
 }
@@ -0,0 +1,21 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	asserts := assert.New(t)
+
+	Version, Commit = "1.2.3", "abcdef"
+	defer func() { Version, Commit = "dev", "" }()
+
+	info := Get()
+	asserts.Equal("1.2.3", info.Version)
+	asserts.Equal("abcdef", info.Commit)
+	asserts.Equal(runtime.Version(), info.GoVersion)
+	asserts.Equal(runtime.GOOS+"/"+runtime.GOARCH, info.Platform)
+}
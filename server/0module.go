@@ -3,16 +3,36 @@ package server
 import (
 	"go.uber.org/fx"
 
+	"github.com/AH-dark/gravatar-with-qq-avatar/server/admin"
 	"github.com/AH-dark/gravatar-with-qq-avatar/server/controllers"
 	"github.com/AH-dark/gravatar-with-qq-avatar/server/controllers/avatar"
+	"github.com/AH-dark/gravatar-with-qq-avatar/server/health"
+	"github.com/AH-dark/gravatar-with-qq-avatar/server/middlewares"
+	"github.com/AH-dark/gravatar-with-qq-avatar/server/rpc"
 )
 
 func Module() fx.Option {
 	return fx.Module("server",
+		fx.Provide(NewHertzLogger),
 		fx.Provide(NewServer),
 		fx.Invoke(RunServer),
 
+		fx.Provide(middlewares.NewRateLimiter),
+		fx.Provide(middlewares.NewURLSignatureVerifier),
+		fx.Provide(middlewares.NewHotlinkProtector),
+
 		fx.Provide(avatar.NewHandlers),
 		fx.Invoke(controllers.BindControllers),
+
+		fx.Provide(rpc.NewAvatarServer),
+		fx.Provide(rpc.NewServer),
+		fx.Invoke(rpc.RunServer),
+
+		fx.Provide(admin.NewServer),
+		fx.Invoke(admin.RunServer),
+
+		fx.Provide(health.NewChecker),
+		// after the servers, so the drain stops first
+		fx.Invoke(health.RegisterDrain),
 	)
 }
@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/version"
+)
+
+const redacted = "[redacted]"
+
+// secretKeys are the parts of config keys whose values are redacted.
+var secretKeys = []string{"key", "password", "secret", "token", "authorization"}
+
+// secretMaps are the config keys whose whole value is redacted, their own keys are arbitrary,
+// e.g. `cdn.purge.http.headers` may hold any authentication header.
+var secretMaps = []string{"headers"}
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+type TraceSampling struct {
+	Rate float64 `json:"rate"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// GetConfig dumps the effective config, with the values of secret looking keys redacted.
+func (h *handlers) GetConfig(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "server.admin.handlers.GetConfig")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redact(h.Reloader.Settings()))
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if isSecretKey(key) {
+				res[key] = redacted
+			} else {
+				res[key] = redact(value)
+			}
+		}
+		return res
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			res[toString(key)] = value
+		}
+		return redact(res)
+	case map[string]string:
+		res := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			res[key] = value
+		}
+		return redact(res)
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, value := range v {
+			res = append(res, redact(value))
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if slices.Contains(secretMaps, key) {
+		return true
+	}
+
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// LogLevel returns the level of the loggers on GET and changes it on PUT with `{"level": "debug"}`.
+func (h *handlers) LogLevel(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "server.admin.handlers.LogLevel")
+	defer span.End()
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, LogLevel{Level: h.Level.String()})
+	case http.MethodPut:
+		var req LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		level, err := zapcore.ParseLevel(req.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.Level.SetLevel(level)
+		h.HertzLogger.SetLevel(hertzLevel(level))
+		otelzap.L().Ctx(ctx).Warn("log level changed", zap.Stringer("level", level))
+
+		writeJSON(w, http.StatusOK, LogLevel{Level: level.String()})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func hertzLevel(level zapcore.Level) hlog.Level {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return hlog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return hlog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return hlog.LevelWarn
+	case level == zapcore.ErrorLevel:
+		return hlog.LevelError
+	default:
+		return hlog.LevelFatal
+	}
+}
+
+// TraceSampling returns the sampling rate of traces on GET and changes it on PUT with `{"rate": 0.5}`.
+func (h *handlers) TraceSampling(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "server.admin.handlers.TraceSampling")
+	defer span.End()
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, TraceSampling{Rate: h.Sampler.Ratio()})
+	case http.MethodPut:
+		var req TraceSampling
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.Sampler.SetRatio(req.Rate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		otelzap.L().Ctx(ctx).Warn("trace sampling rate changed", zap.Float64("rate", req.Rate))
+
+		writeJSON(w, http.StatusOK, TraceSampling{Rate: h.Sampler.Ratio()})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// GetVersion returns the build info of the binary.
+func (h *handlers) GetVersion(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "server.admin.handlers.GetVersion")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, version.Get())
+}
+
+// InvalidateAvatar drops the cached avatars of `?hash=` on POST, to be called after its mapping changed.
+func (h *handlers) InvalidateAvatar(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "server.admin.handlers.InvalidateAvatar")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	hash := strings.ToLower(r.URL.Query().Get("hash"))
+	if hash == "" {
+		http.Error(w, "hash is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Avatar.Invalidate(ctx, hash); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	otelzap.L().Ctx(ctx).Info("avatar invalidated", zap.String("hash", hash))
+	w.WriteHeader(http.StatusNoContent)
+}
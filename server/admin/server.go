@@ -0,0 +1,100 @@
+// Package admin serves the diagnostics of the running process on a separate listener, bound to localhost by default.
+package admin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/observability"
+	hertzloggerzap "github.com/AH-dark/gravatar-with-qq-avatar/pkg/hertzloggerzap"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/server/admin")
+
+type Server struct {
+	*http.Server
+}
+
+type handlers struct {
+	fx.In `ignore-unexported:"true"`
+	// Reloader dumps the running config, including the changes applied since the start
+	Reloader    *config.Reloader
+	Level       zap.AtomicLevel
+	HertzLogger *hertzloggerzap.Logger
+	Sampler     *observability.Sampler
+	Avatar      avatar.Service
+}
+
+func NewServer(ctx context.Context, h handlers) *Server {
+	ctx, span := tracer.Start(ctx, "server.admin.NewServer")
+	defer span.End()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/config", h.GetConfig)
+	mux.HandleFunc("/log/level", h.LogLevel)
+	mux.HandleFunc("/trace/sampling", h.TraceSampling)
+	mux.HandleFunc("/version", h.GetVersion)
+	mux.HandleFunc("/avatar/invalidate", h.InvalidateAvatar)
+
+	return &Server{Server: &http.Server{
+		Handler:  mux,
+		ErrorLog: zap.NewStdLog(otelzap.L().Named("admin")),
+	}}
+}
+
+func RunServer(ctx context.Context, conf *config.Config, svr *Server, lc fx.Lifecycle) error {
+	ctx, span := tracer.Start(ctx, "server.admin.RunServer")
+	defer span.End()
+
+	if !conf.Admin.Enabled {
+		return nil
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			addr := conf.Admin.Listen
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				otelzap.L().Ctx(ctx).Error("listen admin server failed", zap.String("address", addr), zap.Error(err))
+				return err
+			}
+
+			otelzap.L().Ctx(ctx).Info("admin server listening", zap.String("address", lis.Addr().String()))
+			go func() {
+				if err := svr.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					otelzap.L().Error("serve admin server failed", zap.Error(err))
+				}
+			}()
+
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			// profiles may take long, they are cut at the stop timeout
+			if err := svr.Shutdown(ctx); err != nil {
+				otelzap.L().Ctx(ctx).Error("shutdown admin server failed", zap.Error(err))
+				return svr.Close()
+			}
+
+			return nil
+		},
+	})
+
+	return nil
+}
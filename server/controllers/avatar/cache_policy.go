@@ -0,0 +1,103 @@
+package avatar
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/cdn"
+)
+
+const (
+	cachePolicyQQ       = "qq"
+	cachePolicyGravatar = "gravatar"
+	cachePolicyDefault  = "default"
+	cachePolicyNotFound = "not_found"
+)
+
+// CachePolicy describes the Cache-Control header sent for avatars of one source.
+type CachePolicy struct {
+	Public       bool
+	MaxAge       time.Duration
+	SharedMaxAge time.Duration
+	Immutable    bool
+	NoStore      bool
+
+	// StaleWhileRevalidate and StaleIfError let downstream caches serve expired responses
+	// while they revalidate or when we fail, like the service does with its own cache.
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+// newCachePolicy reads the policy from the `server.cache_control.<name>` config section.
+func newCachePolicy(conf *config.Config, name string) CachePolicy {
+	section := conf.Server.CacheControl[name]
+
+	return CachePolicy{
+		Public:       section.Public,
+		MaxAge:       section.MaxAge,
+		SharedMaxAge: section.SharedMaxAge,
+		Immutable:    section.Immutable,
+		NoStore:      section.NoStore,
+
+		StaleWhileRevalidate: section.StaleWhileRevalidate,
+		StaleIfError:         section.StaleIfError,
+	}
+}
+
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+
+	directives := []string{"private"}
+	if p.Public {
+		directives[0] = "public"
+	}
+
+	directives = append(directives, "max-age="+strconv.FormatInt(int64(p.MaxAge/time.Second), 10))
+	if p.SharedMaxAge > 0 {
+		directives = append(directives, "s-maxage="+strconv.FormatInt(int64(p.SharedMaxAge/time.Second), 10))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+strconv.FormatInt(int64(p.StaleWhileRevalidate/time.Second), 10))
+	}
+	if p.StaleIfError > 0 {
+		directives = append(directives, "stale-if-error="+strconv.FormatInt(int64(p.StaleIfError/time.Second), 10))
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// apply writes the Cache-Control and Expires headers of the policy.
+func (p CachePolicy) apply(c *app.RequestContext) {
+	c.Header("Cache-Control", p.String())
+	if !p.NoStore && p.MaxAge > 0 {
+		c.Header("Expires", time.Now().Add(p.MaxAge).UTC().Format(http.TimeFormat))
+	}
+}
+
+// setSurrogateKey tags the response with the hashes, under the header names of the common CDNs,
+// so every size and format of an avatar, and the montages it is part of, can be purged together.
+func setSurrogateKey(c *app.RequestContext, hashes ...string) {
+	keys := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		if hash != "" {
+			keys = append(keys, cdn.SurrogateKey(hash))
+		}
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	c.Header("Surrogate-Key", strings.Join(keys, " "))
+	c.Header("Cache-Tag", strings.Join(keys, ","))
+}
@@ -0,0 +1,44 @@
+package avatar
+
+import (
+	"strings"
+	"time"
+
+	"github.com/AH-dark/bytestring"
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+// weakETag tags the text bodies, they are gzipped on the way out, so the bytes on the wire
+// only match the tag semantically.
+func weakETag(body []byte) string {
+	return `W/"` + avatar.ContentHash(body) + `"`
+}
+
+// notModified evaluates the conditional request headers, If-None-Match takes precedence over
+// If-Modified-Since as RFC 9110 requires.
+func notModified(c *app.RequestContext, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := bytestring.BytesToString(c.GetHeader("If-None-Match")); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+
+	return !c.IfModifiedSince(lastModified)
+}
+
+// etagMatches compares the entity tags of an If-None-Match header with the weak comparison function.
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,9 +2,11 @@ package avatar
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"image/color"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/AH-dark/bytestring"
 	"github.com/cloudwego/hertz/pkg/app"
@@ -12,16 +14,114 @@ import (
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.uber.org/zap"
 
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
 	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
 )
 
+const (
+	defaultSize = 80
+	minSize     = 1
+	maxSize     = 2048
+)
+
+// HeaderAvatarSize reports the dimensions actually served, which are smaller than `s`
+// when the source is smaller and upscaling is capped.
+const HeaderAvatarSize = "X-Avatar-Size"
+
 type GetAvatarRequest struct {
 	Hash string `path:"hash"`
 
-	Size    int64  `query:"s"`
+	Size    *int64 `query:"s"`
 	Default string `query:"d"`
 	Rating  string `query:"r"`
 	Force   string `query:"f"`
+
+	Shape       string `query:"shape"`
+	Radius      int    `query:"radius"`
+	Padding     int    `query:"padding"`
+	Border      int    `query:"border"`
+	BorderColor string `query:"border_color"`
+	Background  string `query:"bg"`
+}
+
+func (req GetAvatarRequest) args(c *app.RequestContext) (avatar.GetAvatarArgs, error) {
+	args := avatar.GetAvatarArgs{
+		Size:         defaultSize,
+		Default:      req.Default,
+		ForceDefault: req.Force == "y",
+		Rating:       req.Rating,
+		EnableWebp: lo.SomeBy(strings.Split(bytestring.BytesToString(c.GetHeader("Accept")), ","), func(item string) bool {
+			return strings.HasPrefix(item, "image/webp")
+		}),
+	}
+
+	if req.Size != nil {
+		if *req.Size < minSize || *req.Size > maxSize {
+			return avatar.GetAvatarArgs{}, fmt.Errorf("s must be between %d and %d, got %d", minSize, maxSize, *req.Size)
+		}
+		args.Size = *req.Size
+	}
+
+	shape, err := req.shape(args.Size)
+	if err != nil {
+		return avatar.GetAvatarArgs{}, err
+	}
+	args.Shape = shape
+
+	return args, nil
+}
+
+func (req GetAvatarRequest) shape(size int64) (imaging.ShapeOptions, error) {
+	opts := imaging.ShapeOptions{
+		Radius:      req.Radius,
+		Padding:     req.Padding,
+		BorderWidth: req.Border,
+		BorderColor: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+
+	switch req.Shape {
+	case "", "square":
+	case "circle":
+		opts.Circle = true
+	default:
+		return opts, fmt.Errorf("unknown shape %q", req.Shape)
+	}
+
+	// the options are in pixels of the requested size, the service scales them down when
+	// a capped upscale serves a smaller image
+	for name, v := range map[string]int{"radius": req.Radius, "padding": req.Padding, "border": req.Border} {
+		if v < 0 || int64(v) > size {
+			return opts, fmt.Errorf("%s must be between 0 and the size %d, got %d", name, size, v)
+		}
+	}
+
+	if req.BorderColor != "" {
+		c, err := imaging.ParseColor(req.BorderColor)
+		if err != nil {
+			return opts, err
+		}
+		opts.BorderColor = c
+	}
+
+	if req.Background != "" {
+		c, err := imaging.ParseColor(req.Background)
+		if err != nil {
+			return opts, err
+		}
+		opts.Background = &c
+	}
+
+	return opts, nil
+}
+
+// metadata reports whether the request asks for the metadata rather than the image, by the `.json`
+// suffix or an Accept header preferring json, and returns the hash without the suffix.
+func (req GetAvatarRequest) metadata(c *app.RequestContext) (string, bool) {
+	if hash, ok := strings.CutSuffix(req.Hash, metadataSuffix); ok {
+		return hash, true
+	}
+
+	return req.Hash, prefersJSON(bytestring.BytesToString(c.GetHeader("Accept")))
 }
 
 func (h *handlers) GetAvatar(ctx context.Context, c *app.RequestContext) {
@@ -35,43 +135,63 @@ func (h *handlers) GetAvatar(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	args := avatar.GetAvatarArgs{
-		Size:         req.Size,
-		Default:      req.Default,
-		ForceDefault: req.Force == "y",
-		Rating:       req.Rating,
-		EnableWebp: lo.SomeBy(strings.Split(bytestring.BytesToString(c.GetHeader("Accept")), ","), func(item string) bool {
-			return strings.HasPrefix(item, "image/webp")
-		}),
+	args, err := req.args(c)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Debug("invalid avatar arguments", zap.Error(err))
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if args.Size <= 0 {
-		args.Size = 80
+	if hash, ok := req.metadata(c); ok {
+		h.getMetadata(ctx, c, hash, args)
+		return
+	}
+
+	res, err := h.getAvatar(ctx, c, req.Hash, args)
+	if res == nil || err != nil {
+		return
 	}
 
-	if args.EnableWebp {
-		c.Header("Content-Type", "image/webp")
+	h.writeAvatar(c, req.Hash, res)
+}
+
+// writeAvatar answers with the avatar or 304, the body of HEAD requests is left out by the server.
+func (h *handlers) writeAvatar(c *app.RequestContext, hash string, res *avatar.Avatar) {
+	if notModified(c, res.ETag, res.LastModified) {
+		c.NotModified()
 	} else {
-		c.Header("Content-Type", "image/png")
+		c.Data(http.StatusOK, res.ContentType, res.Data)
 	}
 
-	avatarData, lastModified, err := h.AvatarService.GetAvatar(ctx, req.Hash, args)
-	if err != nil {
+	// validators and caching headers are required on 304 responses as well
+	c.Header("ETag", res.ETag)
+	if !res.LastModified.IsZero() {
+		c.Header("Last-Modified", res.LastModified.UTC().Format(http.TimeFormat))
+	}
+	h.cachePolicies[string(res.Source)].apply(c)
+	setSurrogateKey(c, hash)
+	c.Header(HeaderAvatarSize, fmt.Sprintf("%dx%d", res.Width, res.Height))
+	c.Header("Vary", "Accept")
+	c.Header("X-Content-Type-Options", "nosniff")
+}
+
+// getAvatar calls the avatar service and answers the errors and misses,
+// the caller only writes the response when an avatar is returned.
+func (h *handlers) getAvatar(ctx context.Context, c *app.RequestContext, hash string, args avatar.GetAvatarArgs) (*avatar.Avatar, error) {
+	res, err := h.AvatarService.GetAvatar(ctx, hash, args)
+	if errors.Is(err, avatar.ErrUpscaleRejected) {
+		c.AbortWithMsg(err.Error(), http.StatusUnprocessableEntity)
+		return nil, err
+	} else if err != nil {
 		otelzap.L().Ctx(ctx).Error("get avatar data failed", zap.Error(err))
 		c.AbortWithStatus(http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	if avatarData == nil {
-		c.NotFound()
-		return
+	if res == nil {
+		h.writeNotFound(c, hash)
+		return nil, nil
 	}
 
-	c.Data(http.StatusOK, lo.If(args.EnableWebp, "image/webp").Else("image/png"), avatarData)
-	if !lastModified.IsZero() {
-		c.Header("Last-Modified", lastModified.UTC().Format(time.RFC1123))
-	}
-	c.Header("Cache-Control", "public, max-age=86400, immutable")
-	c.Header("Expires", time.Now().Add(86400*time.Second).UTC().Format(time.RFC1123))
-	c.Header("X-Content-Type-Options", "nosniff")
+	return res, nil
 }
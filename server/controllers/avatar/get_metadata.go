@@ -0,0 +1,138 @@
+package avatar
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AH-dark/bytestring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+const metadataSuffix = ".json"
+
+// formats are the content types an avatar can be requested in.
+var formats = []string{"image/png", "image/webp"}
+
+// Metadata explains which image GetAvatar serves for a request and why.
+type Metadata struct {
+	Hash         string     `json:"hash"`
+	Source       string     `json:"source"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+	NativeWidth  int        `json:"native_width"`
+	NativeHeight int        `json:"native_height"`
+	Width        int        `json:"width"`
+	Height       int        `json:"height"`
+	ContentType  string     `json:"content_type"`
+	Formats      []string   `json:"formats"`
+	ContentHash  string     `json:"content_hash"`
+	ETag         string     `json:"etag"`
+	CacheControl string     `json:"cache_control"`
+	// QQ is only included for authorized callers.
+	QQ int64 `json:"qq,omitempty"`
+}
+
+// prefersJSON reports whether the Accept header ranks application/json above every image type.
+func prefersJSON(accept string) bool {
+	var jsonQ, imageQ float64
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+
+		switch mediaType = strings.ToLower(strings.TrimSpace(mediaType)); {
+		case mediaType == "application/json":
+			jsonQ = max(jsonQ, q)
+		case strings.HasPrefix(mediaType, "image/"):
+			imageQ = max(imageQ, q)
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > imageQ
+}
+
+// authorized reports whether the request carries one of the bearer tokens.
+func authorized(c *app.RequestContext, tokens []string) bool {
+	token, ok := strings.CutPrefix(bytestring.BytesToString(c.GetHeader("Authorization")), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getMetadata answers `/avatar/:hash.json`, and requests preferring json, with the metadata of the avatar.
+func (h *handlers) getMetadata(ctx context.Context, c *app.RequestContext, hash string, args avatar.GetAvatarArgs) {
+	ctx, span := tracer.Start(ctx, "server.controllers.avatarData.getMetadata")
+	defer span.End()
+
+	res, err := h.getAvatar(ctx, c, hash, args)
+	if res == nil || err != nil {
+		return
+	}
+
+	policy := h.cachePolicies[string(res.Source)]
+	metadata := Metadata{
+		Hash:         hash,
+		Source:       string(res.Source),
+		NativeWidth:  res.NativeWidth,
+		NativeHeight: res.NativeHeight,
+		Width:        res.Width,
+		Height:       res.Height,
+		ContentType:  res.ContentType,
+		Formats:      formats,
+		ContentHash:  res.ContentHash,
+		ETag:         res.ETag,
+		CacheControl: policy.String(),
+	}
+	if !res.LastModified.IsZero() {
+		metadata.LastModified = &res.LastModified
+	}
+
+	authorized := authorized(c, h.metadataTokens)
+	if authorized {
+		metadata.QQ = res.QQ
+	}
+
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Error("marshal avatar metadata failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	etag := weakETag(body)
+	if notModified(c, etag, res.LastModified) {
+		c.NotModified()
+	} else {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+
+	c.Header("ETag", etag)
+	if authorized {
+		// the qq number must not end up in shared caches
+		c.Header("Cache-Control", "private, no-store")
+	} else {
+		policy.apply(c)
+		setSurrogateKey(c, hash)
+	}
+	c.Header("Vary", "Accept, Authorization")
+}
@@ -0,0 +1,77 @@
+package avatar
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+func TestPrefersJSON(t *testing.T) {
+	asserts := assert.New(t)
+
+	for accept, expected := range map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"application/json":                  true,
+		"Application/JSON":                  true,
+		"image/webp,*/*":                    false,
+		"application/json, image/png":       false,
+		"application/json, image/*;q=0.8":   true,
+		"image/png;q=0.9, application/json": true,
+		"application/json;q=0.5, image/*":   false,
+		"application/json;q=0":              false,
+	} {
+		asserts.Equal(expected, prefersJSON(accept), accept)
+	}
+}
+
+func TestHandlers_GetMetadata(t *testing.T) {
+	asserts := assert.New(t)
+
+	conf := newTestConfig()
+	conf.Server.Metadata.Tokens = []string{"t0ken"}
+	svc := newFakeService(map[string]*avatar.Avatar{"known": testAvatar})
+	e := newTestEngine(handlers{Config: conf, AvatarService: svc})
+
+	metadata := func(resp *ut.ResponseRecorder) Metadata {
+		var m Metadata
+		asserts.NoError(json.Unmarshal(resp.Result().Body(), &m))
+		return m
+	}
+
+	// the suffix and an Accept header preferring json both answer the metadata
+	for _, resp := range []*ut.ResponseRecorder{
+		ut.PerformRequest(e, http.MethodGet, "/avatar/known.json", nil),
+		ut.PerformRequest(e, http.MethodGet, "/avatar/known", nil, ut.Header{Key: "Accept", Value: "application/json"}),
+		ut.PerformRequest(e, http.MethodGet, "/avatar/known", nil, ut.Header{Key: "Authorization", Value: "Bearer wrong"}, ut.Header{Key: "Accept", Value: "application/json"}),
+	} {
+		res := resp.Result()
+		asserts.Equal(http.StatusOK, res.StatusCode())
+		asserts.Equal("application/json; charset=utf-8", string(res.Header.ContentType()))
+		asserts.Equal("public, max-age=3600", res.Header.Get("Cache-Control"))
+		asserts.Equal("Accept, Authorization", res.Header.Get("Vary"))
+
+		m := metadata(resp)
+		asserts.Equal("known", m.Hash)
+		asserts.Equal(string(avatar.SourceQQ), m.Source)
+		asserts.Equal(`"abc"`, m.ETag)
+		// the qq number is redacted without a valid token
+		asserts.Zero(m.QQ)
+	}
+
+	// an image preferring Accept header still gets the image
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/known", nil, ut.Header{Key: "Accept", Value: "image/png, application/json;q=0.5"}).Result()
+	asserts.Equal("image/png", string(resp.Header.ContentType()))
+
+	// a valid token discloses the qq number, and keeps the answer out of shared caches
+	authorized := ut.PerformRequest(e, http.MethodGet, "/avatar/known.json", nil, ut.Header{Key: "Authorization", Value: "Bearer t0ken"})
+	asserts.Equal(http.StatusOK, authorized.Result().StatusCode())
+	asserts.Equal("private, no-store", authorized.Result().Header.Get("Cache-Control"))
+	asserts.Empty(authorized.Result().Header.Get("Surrogate-Key"))
+	asserts.Equal(int64(10001), metadata(authorized).QQ)
+}
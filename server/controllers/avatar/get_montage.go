@@ -0,0 +1,114 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+type GetMontageRequest struct {
+	// Hashes is the comma separated list of members.
+	Hashes string `query:"hashes"`
+	Layout string `query:"layout"`
+}
+
+func (req GetMontageRequest) hashes() ([]string, error) {
+	hashes := make([]string, 0, strings.Count(req.Hashes, ",")+1)
+	for _, hash := range strings.Split(req.Hashes, ",") {
+		if hash = strings.TrimSpace(hash); hash != "" {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	if len(hashes) == 0 {
+		return nil, errors.New("hashes must not be empty")
+	}
+
+	return hashes, nil
+}
+
+// GetMontage composites the avatars of `hashes` into one image, with the layout grid, stack or group.
+// `s` is the width of the montage and the shape options apply to the whole of it.
+func (h *handlers) GetMontage(ctx context.Context, c *app.RequestContext) {
+	ctx, span := tracer.Start(ctx, "server.controllers.avatarData.GetMontage")
+	defer span.End()
+
+	var req GetAvatarRequest
+	var montageReq GetMontageRequest
+	if err := c.Bind(&req); err != nil {
+		otelzap.L().Ctx(ctx).Error("bind request failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	} else if err := c.Bind(&montageReq); err != nil {
+		otelzap.L().Ctx(ctx).Error("bind request failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	args, err := req.args(c)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Debug("invalid avatar arguments", zap.Error(err))
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hashes, err := montageReq.hashes()
+	if err != nil {
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	layout := imaging.LayoutGrid
+	if montageReq.Layout != "" {
+		if layout, err = imaging.ParseLayout(montageReq.Layout); err != nil {
+			c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res, err := h.AvatarService.GetMontage(ctx, hashes, avatar.MontageArgs{GetAvatarArgs: args, Layout: layout})
+	if errors.Is(err, avatar.ErrTooManyMembers) {
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, avatar.ErrUpscaleRejected) {
+		c.AbortWithMsg(err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("get avatar montage failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if res == nil {
+		c.NotFound()
+		h.cachePolicies[cachePolicyNotFound].apply(c)
+		setSurrogateKey(c, hashes...)
+		return
+	}
+
+	if notModified(c, res.ETag, res.LastModified) {
+		c.NotModified()
+	} else {
+		c.Data(http.StatusOK, res.ContentType, res.Data)
+	}
+
+	c.Header("ETag", res.ETag)
+	if !res.LastModified.IsZero() {
+		c.Header("Last-Modified", res.LastModified.UTC().Format(http.TimeFormat))
+	}
+	// members come from different sources, the policy of default images is the most conservative one
+	h.cachePolicies[cachePolicyDefault].apply(c)
+	setSurrogateKey(c, hashes...)
+	c.Header(HeaderAvatarSize, fmt.Sprintf("%dx%d", res.Width, res.Height))
+	c.Header("Vary", "Accept")
+	c.Header("X-Content-Type-Options", "nosniff")
+}
@@ -0,0 +1,72 @@
+package avatar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+// GetPlaceholder returns the blurhash, colors and a tiny preview of the avatar the same request would get.
+func (h *handlers) GetPlaceholder(ctx context.Context, c *app.RequestContext) {
+	ctx, span := tracer.Start(ctx, "server.controllers.avatarData.GetPlaceholder")
+	defer span.End()
+
+	var req GetAvatarRequest
+	if err := c.Bind(&req); err != nil {
+		otelzap.L().Ctx(ctx).Error("bind request failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	args, err := req.args(c)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Debug("invalid avatar arguments", zap.Error(err))
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.AvatarService.GetPlaceholder(ctx, req.Hash, args)
+	if errors.Is(err, avatar.ErrUpscaleRejected) {
+		c.AbortWithMsg(err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("get avatar placeholder failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if res == nil {
+		c.NotFound()
+		h.cachePolicies[cachePolicyNotFound].apply(c)
+		setSurrogateKey(c, req.Hash)
+		return
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Error("marshal avatar placeholder failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	etag := weakETag(body)
+	if notModified(c, etag, res.LastModified) {
+		c.NotModified()
+	} else {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+
+	c.Header("ETag", etag)
+	if !res.LastModified.IsZero() {
+		c.Header("Last-Modified", res.LastModified.UTC().Format(http.TimeFormat))
+	}
+	h.cachePolicies[string(res.Source)].apply(c)
+	setSurrogateKey(c, req.Hash)
+}
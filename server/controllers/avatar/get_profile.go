@@ -0,0 +1,183 @@
+package avatar
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+type GetProfileRequest struct {
+	// File is the hash with the format extension, e.g. `<hash>.json`.
+	File string `path:"file"`
+}
+
+// profileResponse is the xml document of the gravatar profile api, the json one is `{"entry": [profile]}`.
+type profileResponse struct {
+	XMLName xml.Name         `json:"-" xml:"response"`
+	Entry   []avatar.Profile `json:"entry" xml:"entry"`
+}
+
+type profileEncoder func(profile *avatar.Profile) ([]byte, error)
+
+var profileFormats = map[string]struct {
+	contentType string
+	encode      profileEncoder
+}{
+	".json": {"application/json; charset=utf-8", encodeProfileJSON},
+	".xml":  {"application/xml; charset=utf-8", encodeProfileXML},
+	".vcf":  {"text/vcard; charset=utf-8", encodeProfileVCard},
+}
+
+func encodeProfileJSON(profile *avatar.Profile) ([]byte, error) {
+	return json.Marshal(profileResponse{Entry: []avatar.Profile{*profile}})
+}
+
+func encodeProfileXML(profile *avatar.Profile) ([]byte, error) {
+	body, err := xml.Marshal(profileResponse{Entry: []avatar.Profile{*profile}})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+var vCardEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`)
+
+func encodeProfileVCard(profile *avatar.Profile) ([]byte, error) {
+	var b strings.Builder
+	line := func(name, value string) {
+		b.WriteString(name + ":" + value + "\r\n")
+	}
+
+	line("BEGIN", "VCARD")
+	line("VERSION", "3.0")
+	line("FN", vCardEscaper.Replace(profile.DisplayName))
+	if profile.Name != nil {
+		line("N", vCardEscaper.Replace(profile.Name.FamilyName)+";"+vCardEscaper.Replace(profile.Name.GivenName)+";;;")
+	} else {
+		line("N", ";;;;")
+	}
+	if profile.PreferredUsername != "" {
+		line("NICKNAME", vCardEscaper.Replace(profile.PreferredUsername))
+	}
+	if profile.ThumbnailURL != "" {
+		line("PHOTO;VALUE=URI", profile.ThumbnailURL)
+	}
+	if profile.ProfileURL != "" {
+		line("URL", profile.ProfileURL)
+	}
+	for _, u := range profile.URLs {
+		line("URL", u.Value)
+	}
+	if profile.AboutMe != "" {
+		line("NOTE", vCardEscaper.Replace(profile.AboutMe))
+	}
+	line("END", "VCARD")
+
+	return []byte(b.String()), nil
+}
+
+// publicURL is the scheme and host this service is reached at, without trailing slash.
+func (h *handlers) publicURL(c *app.RequestContext) string {
+	base := h.Config.Server.PublicURL
+	if base == "" {
+		base = string(c.URI().Scheme()) + "://" + string(c.Host())
+	}
+
+	return strings.TrimSuffix(base, "/")
+}
+
+// signedAvatarURL is the url of the avatar of the hash with the query, signed when url signing is enabled.
+func (h *handlers) signedAvatarURL(publicURL, hash string, query url.Values, expires time.Time) (string, error) {
+	path := "/avatar/" + hash
+
+	query, err := h.URLSigner.Sign(path, query, expires)
+	if err != nil {
+		return "", err
+	}
+
+	if len(query) == 0 {
+		return publicURL + path, nil
+	}
+
+	return publicURL + path + "?" + query.Encode(), nil
+}
+
+// GetProfile answers the gravatar profile api, `/<hash>.json`, `.xml` and `.vcf`.
+func (h *handlers) GetProfile(ctx context.Context, c *app.RequestContext) {
+	ctx, span := tracer.Start(ctx, "server.controllers.avatarData.GetProfile")
+	defer span.End()
+
+	var req GetProfileRequest
+	if err := c.Bind(&req); err != nil {
+		otelzap.L().Ctx(ctx).Error("bind request failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ext := path.Ext(req.File)
+	format, ok := profileFormats[ext]
+	if !ok {
+		c.NotFound()
+		return
+	}
+	hash := strings.TrimSuffix(req.File, ext)
+
+	// signed without expiry, the profile is cached and its etag must not change with every request
+	avatarURL, err := h.signedAvatarURL(h.publicURL(c), hash, nil, time.Time{})
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("sign avatar url failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	profile, err := h.AvatarService.GetProfile(ctx, hash, avatar.GetProfileArgs{AvatarURL: avatarURL})
+	if err != nil {
+		otelzap.L().Ctx(ctx).Error("get profile failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if profile == nil {
+		// gravatar answers the same
+		c.Data(http.StatusNotFound, "application/json; charset=utf-8", []byte(`"User not found"`))
+		h.cachePolicies[cachePolicyNotFound].apply(c)
+		setSurrogateKey(c, hash)
+		return
+	}
+
+	body, err := format.encode(profile)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Error("encode profile failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	etag := weakETag(body)
+	if notModified(c, etag, time.Time{}) {
+		c.NotModified()
+	} else {
+		c.Data(http.StatusOK, format.contentType, body)
+	}
+
+	c.Header("ETag", etag)
+	policy := h.cachePolicies[string(profile.Source)]
+	if h.Config.Server.PublicURL == "" {
+		// the avatar urls come from the Host header, shared caches must not hand them to other clients
+		policy.Public, policy.SharedMaxAge = false, 0
+	}
+	policy.apply(c)
+	setSurrogateKey(c, hash)
+}
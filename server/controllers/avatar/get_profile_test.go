@@ -0,0 +1,71 @@
+package avatar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	hertzconfig "github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/urlsign"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+func TestHandlers_GetProfileSigned(t *testing.T) {
+	asserts := assert.New(t)
+
+	conf := newTestConfig()
+	conf.Server.URLSigning.Enabled = true
+	signer := newTestURLSigner(t, conf)
+	svc := newFakeService(map[string]*avatar.Avatar{"known": testAvatar})
+	e := newTestEngine(handlers{Config: conf, AvatarService: svc, URLSigner: signer})
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/known.json", nil).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+
+	var body profileResponse
+	asserts.NoError(json.Unmarshal(resp.Body(), &body))
+	asserts.Len(body.Entry, 1)
+	profile := body.Entry[0]
+
+	// the synthesized avatar urls are signed and pass the url signature middleware
+	thumbnail, err := url.Parse(profile.ThumbnailURL)
+	asserts.NoError(err)
+	asserts.True(strings.HasPrefix(profile.ThumbnailURL, "https://avatar.example.com/avatar/known?"))
+	asserts.Equal("current", thumbnail.Query().Get(urlsign.ParamKeyID))
+	asserts.Equal([]avatar.ProfilePhoto{{Value: profile.ThumbnailURL, Type: "thumbnail"}}, profile.Photos)
+
+	verify := route.NewEngine(hertzconfig.NewOptions(nil))
+	verify.GET("/avatar/:hash", signer.Middleware(), func(ctx context.Context, c *app.RequestContext) { c.Status(http.StatusOK) })
+	asserts.Equal(http.StatusOK, ut.PerformRequest(verify, http.MethodGet, thumbnail.RequestURI(), nil).Result().StatusCode())
+	asserts.Equal(http.StatusForbidden, ut.PerformRequest(verify, http.MethodGet, "/avatar/known", nil).Result().StatusCode())
+
+	// the signature doesn't expire, so the cached profile keeps its etag
+	again := ut.PerformRequest(e, http.MethodGet, "/known.json", nil).Result()
+	asserts.Equal(resp.Header.Get("ETag"), again.Header.Get("ETag"))
+}
+
+func TestHandlers_GetProfileUnsigned(t *testing.T) {
+	asserts := assert.New(t)
+
+	conf := newTestConfig()
+	svc := newFakeService(map[string]*avatar.Avatar{"known": testAvatar})
+	e := newTestEngine(handlers{Config: conf, AvatarService: svc, URLSigner: newTestURLSigner(t, conf)})
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/known.json", nil).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+
+	var body profileResponse
+	asserts.NoError(json.Unmarshal(resp.Body(), &body))
+	asserts.Equal("https://avatar.example.com/avatar/known", body.Entry[0].ThumbnailURL)
+
+	resp = ut.PerformRequest(e, http.MethodGet, "/unknown.json", nil).Result()
+	asserts.Equal(http.StatusNotFound, resp.StatusCode())
+}
@@ -2,11 +2,14 @@ package avatar
 
 import (
 	"context"
+	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/fx"
 
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/server/middlewares"
 	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
 )
 
@@ -14,13 +17,43 @@ var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/internal/se
 
 type Handlers interface {
 	GetAvatar(ctx context.Context, c *app.RequestContext)
+	HeadAvatar(ctx context.Context, c *app.RequestContext)
+	GetPlaceholder(ctx context.Context, c *app.RequestContext)
+	GetProfile(ctx context.Context, c *app.RequestContext)
+	ResolveBatch(ctx context.Context, c *app.RequestContext)
+	GetMontage(ctx context.Context, c *app.RequestContext)
 }
 
 type handlers struct {
-	fx.In
+	fx.In         `ignore-unexported:"true"`
+	Config        *config.Config
 	AvatarService avatar.Service
+	// URLSigner signs the avatar urls of batch results when url signing is enabled
+	URLSigner *middlewares.URLSignatureVerifier
+
+	cachePolicies  map[string]CachePolicy
+	metadataTokens []string
+	batchTokens    []string
+
+	batchMaxHashes      int
+	batchWorkers        int
+	batchInlineMaxBytes int
+	batchURLTTL         time.Duration
 }
 
 func NewHandlers(h handlers) Handlers {
+	h.cachePolicies = make(map[string]CachePolicy)
+	for _, name := range []string{cachePolicyQQ, cachePolicyGravatar, cachePolicyDefault, cachePolicyNotFound} {
+		h.cachePolicies[name] = newCachePolicy(h.Config, name)
+	}
+
+	h.metadataTokens = h.Config.Server.Metadata.Tokens
+
+	h.batchTokens = h.Config.Server.Batch.Tokens
+	h.batchMaxHashes = h.Config.Server.Batch.MaxHashes
+	h.batchWorkers = max(h.Config.Server.Batch.Workers, 1)
+	h.batchInlineMaxBytes = h.Config.Server.Batch.InlineMaxBytes
+	h.batchURLTTL = h.Config.Server.Batch.URLTTL
+
 	return &h
 }
@@ -0,0 +1,133 @@
+package avatar
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	hertzconfig "github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/route"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/server/middlewares"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+// fakeService serves the avatars it holds and counts the calls, the methods a test doesn't need panic.
+type fakeService struct {
+	avatar.Service
+
+	mu      sync.Mutex
+	avatars map[string]*avatar.Avatar
+	cached  bool
+	calls   map[string]int
+}
+
+func newFakeService(avatars map[string]*avatar.Avatar) *fakeService {
+	return &fakeService{avatars: avatars, calls: make(map[string]int)}
+}
+
+func (s *fakeService) called(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+func (s *fakeService) call(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[method]++
+}
+
+func (s *fakeService) GetAvatar(_ context.Context, hash string, _ avatar.GetAvatarArgs) (*avatar.Avatar, error) {
+	s.call("GetAvatar")
+	return s.avatars[hash], nil
+}
+
+func (s *fakeService) CachedAvatar(_ context.Context, hash string, _ avatar.GetAvatarArgs) (*avatar.Avatar, bool) {
+	s.call("CachedAvatar")
+	return s.avatars[hash], s.cached
+}
+
+// GetProfile synthesizes the profile of the avatars it holds like the service does for qq numbers.
+func (s *fakeService) GetProfile(_ context.Context, hash string, args avatar.GetProfileArgs) (*avatar.Profile, error) {
+	s.call("GetProfile")
+	if s.avatars[hash] == nil {
+		return nil, nil
+	}
+
+	return &avatar.Profile{
+		ID:           hash,
+		Hash:         hash,
+		RequestHash:  hash,
+		ThumbnailURL: args.AvatarURL,
+		Photos:       []avatar.ProfilePhoto{{Value: args.AvatarURL, Type: "thumbnail"}},
+		Source:       avatar.SourceQQ,
+	}, nil
+}
+
+func (s *fakeService) Exists(_ context.Context, hash string, _ avatar.GetAvatarArgs) (avatar.Source, error) {
+	s.call("Exists")
+	if res := s.avatars[hash]; res != nil {
+		return res.Source, nil
+	}
+
+	return "", nil
+}
+
+var testAvatar = &avatar.Avatar{
+	Data:         []byte("png"),
+	ContentType:  "image/png",
+	ContentHash:  "abc",
+	ETag:         `"abc"`,
+	Width:        80,
+	Height:       80,
+	LastModified: time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC),
+	Source:       avatar.SourceQQ,
+	NativeWidth:  100,
+	NativeHeight: 100,
+	QQ:           10001,
+}
+
+func newTestConfig() *config.Config {
+	conf := &config.Config{}
+	conf.Server.CacheControl = map[string]config.CacheControlConfig{
+		cachePolicyQQ:       {Public: true, MaxAge: time.Hour},
+		cachePolicyGravatar: {Public: true, MaxAge: time.Hour},
+		cachePolicyDefault:  {Public: true, MaxAge: 10 * time.Minute},
+		cachePolicyNotFound: {Public: true, MaxAge: time.Minute},
+	}
+	conf.Server.Batch.MaxHashes = 10
+	conf.Server.Batch.InlineMaxBytes = 1024
+	conf.Server.Batch.URLTTL = time.Hour
+	conf.Server.PublicURL = "https://avatar.example.com"
+	conf.Server.URLSigning = config.URLSigningConfig{
+		Keys: []config.SigningKeyConfig{{ID: "current", Secret: "s3cret"}},
+	}
+
+	return conf
+}
+
+func newTestURLSigner(t *testing.T, conf *config.Config) *middlewares.URLSignatureVerifier {
+	v, err := middlewares.NewURLSignatureVerifier(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+// newTestEngine routes the avatar endpoints to the handlers like BindControllers, without the middlewares.
+func newTestEngine(h handlers) *route.Engine {
+	hs := NewHandlers(h)
+
+	e := route.NewEngine(hertzconfig.NewOptions(nil))
+	e.GET("/avatar/:hash", hs.GetAvatar)
+	e.HEAD("/avatar/:hash", hs.HeadAvatar)
+	e.POST("/avatar/_batch", hs.ResolveBatch)
+	e.GET("/:file", hs.GetProfile)
+	return e
+}
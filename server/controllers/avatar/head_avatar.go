@@ -0,0 +1,85 @@
+package avatar
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/samber/lo"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+// HeadAvatar answers with the headers GET would send. Metadata requests are negotiated like GET
+// and answered by it. Cached avatars are answered from the cache, otherwise the mapping store and
+// lightweight upstream probes tell the source apart without processing any image, and the response
+// carries the headers known without the image, leaving out the validators and the size.
+func (h *handlers) HeadAvatar(ctx context.Context, c *app.RequestContext) {
+	ctx, span := tracer.Start(ctx, "server.controllers.avatarData.HeadAvatar")
+	defer span.End()
+
+	var req GetAvatarRequest
+	if err := c.Bind(&req); err != nil {
+		otelzap.L().Ctx(ctx).Error("bind request failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	args, err := req.args(c)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Debug("invalid avatar arguments", zap.Error(err))
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the server leaves out the body of HEAD requests
+	if hash, ok := req.metadata(c); ok {
+		h.getMetadata(ctx, c, hash, args)
+		return
+	}
+
+	if res, ok := h.AvatarService.CachedAvatar(ctx, req.Hash, args); ok {
+		if res == nil {
+			h.writeNotFound(c, req.Hash)
+			return
+		}
+
+		h.writeAvatar(c, req.Hash, res)
+		return
+	}
+
+	source := avatar.SourceDefault
+	if !args.ForceDefault {
+		source, err = h.AvatarService.Exists(ctx, req.Hash, args)
+		if err != nil {
+			otelzap.L().Ctx(ctx).Error("check avatar existence failed", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if source == "" {
+		// any default other than 404 renders an image when the hash has no avatar
+		if args.Default == "" || args.Default == "404" {
+			h.writeNotFound(c, req.Hash)
+			return
+		}
+
+		source = avatar.SourceDefault
+	}
+
+	c.SetStatusCode(http.StatusOK)
+	c.SetContentType(lo.If(args.EnableWebp, "image/webp").Else("image/png"))
+	h.cachePolicies[string(source)].apply(c)
+	setSurrogateKey(c, req.Hash)
+	c.Header("Vary", "Accept")
+	c.Header("X-Content-Type-Options", "nosniff")
+}
+
+func (h *handlers) writeNotFound(c *app.RequestContext, hash string) {
+	c.NotFound()
+	h.cachePolicies[cachePolicyNotFound].apply(c)
+	setSurrogateKey(c, hash)
+}
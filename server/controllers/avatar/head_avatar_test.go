@@ -0,0 +1,67 @@
+package avatar
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+func TestHandlers_HeadAvatar(t *testing.T) {
+	asserts := assert.New(t)
+
+	svc := newFakeService(map[string]*avatar.Avatar{"known": testAvatar})
+	e := newTestEngine(handlers{Config: newTestConfig(), AvatarService: svc})
+
+	// a cache miss is answered from the probe, without rendering the image
+	resp := ut.PerformRequest(e, http.MethodHead, "/avatar/known", nil).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+	asserts.Equal("image/png", string(resp.Header.ContentType()))
+	asserts.Equal("public, max-age=3600", resp.Header.Get("Cache-Control"))
+	asserts.Empty(resp.Header.Get("ETag"))
+	asserts.Equal(1, svc.called("Exists"))
+	asserts.Zero(svc.called("GetAvatar"))
+
+	resp = ut.PerformRequest(e, http.MethodHead, "/avatar/unknown", nil).Result()
+	asserts.Equal(http.StatusNotFound, resp.StatusCode())
+	asserts.Equal("public, max-age=60", resp.Header.Get("Cache-Control"))
+
+	// a default renders an image for hashes without an avatar
+	resp = ut.PerformRequest(e, http.MethodHead, "/avatar/unknown?d=identicon", nil).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+	asserts.Equal("public, max-age=600", resp.Header.Get("Cache-Control"))
+	asserts.Zero(svc.called("GetAvatar"))
+
+	// cached avatars send the headers of GET
+	svc.cached = true
+	get := ut.PerformRequest(e, http.MethodGet, "/avatar/known", nil).Result()
+	resp = ut.PerformRequest(e, http.MethodHead, "/avatar/known", nil).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+	for _, header := range []string{"ETag", "Last-Modified", "Cache-Control", HeaderAvatarSize, "Content-Type"} {
+		asserts.Equal(get.Header.Get(header), resp.Header.Get(header), header)
+	}
+	asserts.Equal(`"abc"`, resp.Header.Get("ETag"))
+}
+
+func TestHandlers_HeadAvatarMetadata(t *testing.T) {
+	asserts := assert.New(t)
+
+	svc := newFakeService(map[string]*avatar.Avatar{"known": testAvatar})
+	e := newTestEngine(handlers{Config: newTestConfig(), AvatarService: svc})
+
+	// HEAD negotiates the metadata like GET, by suffix and by Accept
+	get := ut.PerformRequest(e, http.MethodGet, "/avatar/known.json", nil).Result()
+	for _, resp := range []*ut.ResponseRecorder{
+		ut.PerformRequest(e, http.MethodHead, "/avatar/known.json", nil),
+		ut.PerformRequest(e, http.MethodHead, "/avatar/known", nil, ut.Header{Key: "Accept", Value: "application/json"}),
+	} {
+		res := resp.Result()
+		asserts.Equal(http.StatusOK, res.StatusCode())
+		asserts.Equal("application/json; charset=utf-8", string(res.Header.ContentType()))
+		asserts.Equal(get.Header.Get("ETag"), res.Header.Get("ETag"))
+	}
+	asserts.Zero(svc.called("Exists"))
+}
@@ -0,0 +1,211 @@
+package avatar
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+type ResolveBatchRequest struct {
+	Hashes  []string `json:"hashes"`
+	Size    *int64   `json:"s"`
+	Default string   `json:"d"`
+	Rating  string   `json:"r"`
+	Force   bool     `json:"f"`
+	// Format is png or webp, png by default.
+	Format string `json:"format"`
+	// Inline embeds avatars up to `server.batch.inline_max_bytes` as data uris, for trusted callers only.
+	Inline bool `json:"inline"`
+}
+
+type ResolveBatchResult struct {
+	Hash    string `json:"hash"`
+	Exists  bool   `json:"exists"`
+	Source  string `json:"source,omitempty"`
+	ETag    string `json:"etag,omitempty"`
+	URL     string `json:"url"`
+	DataURI string `json:"data_uri,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ResolveBatchResponse struct {
+	Avatars []ResolveBatchResult `json:"avatars"`
+}
+
+func (req ResolveBatchRequest) args(maxHashes int) (avatar.GetAvatarArgs, error) {
+	if len(req.Hashes) == 0 || len(req.Hashes) > maxHashes {
+		return avatar.GetAvatarArgs{}, fmt.Errorf("hashes must have between 1 and %d entries, got %d", maxHashes, len(req.Hashes))
+	}
+
+	args := avatar.GetAvatarArgs{
+		Size:         defaultSize,
+		Default:      req.Default,
+		ForceDefault: req.Force,
+		Rating:       req.Rating,
+	}
+
+	if req.Size != nil {
+		if *req.Size < minSize || *req.Size > maxSize {
+			return avatar.GetAvatarArgs{}, fmt.Errorf("s must be between %d and %d, got %d", minSize, maxSize, *req.Size)
+		}
+		args.Size = *req.Size
+	}
+
+	switch req.Format {
+	case "", "png":
+	case "webp":
+		args.EnableWebp = true
+	default:
+		return avatar.GetAvatarArgs{}, fmt.Errorf("unknown format %q", req.Format)
+	}
+
+	return args, nil
+}
+
+// BatchCost charges a batch one rate limit token per hash, as much as requesting the avatars one by one.
+func BatchCost(c *app.RequestContext) int {
+	var req struct {
+		Hashes []string `json:"hashes"`
+	}
+	if err := json.Unmarshal(c.Request.Body(), &req); err != nil {
+		return 1
+	}
+
+	return len(req.Hashes)
+}
+
+// query is the avatar url query with the options of the batch, without the defaults.
+func (req ResolveBatchRequest) query() url.Values {
+	query := url.Values{}
+	if req.Size != nil && *req.Size != defaultSize {
+		query.Set("s", strconv.FormatInt(*req.Size, 10))
+	}
+	if req.Default != "" {
+		query.Set("d", req.Default)
+	}
+	if req.Force {
+		query.Set("f", "y")
+	}
+	if req.Rating != "" {
+		query.Set("r", req.Rating)
+	}
+
+	return query
+}
+
+// avatarURL is the url of the avatar with the options of the batch, signed for trusted callers when
+// url signing is enabled. Other callers get the url they could have built themselves.
+func (h *handlers) avatarURL(req ResolveBatchRequest, publicURL, hash string, trusted bool) (string, error) {
+	if !trusted {
+		avatarURL := publicURL + "/avatar/" + hash
+		if query := req.query(); len(query) > 0 {
+			avatarURL += "?" + query.Encode()
+		}
+
+		return avatarURL, nil
+	}
+
+	var expires time.Time
+	if h.batchURLTTL > 0 {
+		expires = time.Now().Add(h.batchURLTTL)
+	}
+
+	return h.signedAvatarURL(publicURL, hash, req.query(), expires)
+}
+
+// ResolveBatch resolves the avatars of many hashes at once, on `server.batch.workers` goroutines.
+func (h *handlers) ResolveBatch(ctx context.Context, c *app.RequestContext) {
+	ctx, span := tracer.Start(ctx, "server.controllers.avatarData.ResolveBatch")
+	defer span.End()
+
+	var req ResolveBatchRequest
+	if err := c.BindJSON(&req); err != nil {
+		otelzap.L().Ctx(ctx).Debug("bind request failed", zap.Error(err))
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	args, err := req.args(h.batchMaxHashes)
+	if err != nil {
+		c.AbortWithMsg(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the request context is not safe for concurrent use
+	publicURL := h.publicURL(c)
+	// the route has no url signature or hotlink check, anonymous callers must not get around them here
+	trusted := authorized(c, h.batchTokens)
+
+	results := make([]ResolveBatchResult, len(req.Hashes))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(h.batchWorkers, len(req.Hashes)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = h.resolve(ctx, req, args, publicURL, req.Hashes[i], trusted)
+			}
+		}()
+	}
+
+	for i := range req.Hashes {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	c.JSON(http.StatusOK, ResolveBatchResponse{Avatars: results})
+	c.Header("Cache-Control", "no-store")
+}
+
+func (h *handlers) resolve(ctx context.Context, req ResolveBatchRequest, args avatar.GetAvatarArgs, publicURL, hash string, trusted bool) ResolveBatchResult {
+	ctx, span := tracer.Start(ctx, "server.controllers.avatarData.resolve")
+	defer span.End()
+
+	result := ResolveBatchResult{Hash: hash}
+
+	avatarURL, err := h.avatarURL(req, publicURL, hash, trusted)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("sign avatar url failed", zap.String("hash", hash), zap.Error(err))
+		result.Error = "sign avatar url failed"
+		return result
+	}
+	result.URL = avatarURL
+
+	res, err := h.AvatarService.GetAvatar(ctx, hash, args)
+	if errors.Is(err, avatar.ErrUpscaleRejected) {
+		result.Error = err.Error()
+		return result
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("get avatar data failed", zap.String("hash", hash), zap.Error(err))
+		result.Error = "resolve avatar failed"
+		return result
+	} else if res == nil {
+		return result
+	}
+
+	result.Exists = true
+	result.Source = string(res.Source)
+	result.ETag = res.ETag
+	if trusted && req.Inline && len(res.Data) <= h.batchInlineMaxBytes {
+		result.DataURI = "data:" + res.ContentType + ";base64," + base64.StdEncoding.EncodeToString(res.Data)
+	}
+
+	return result
+}
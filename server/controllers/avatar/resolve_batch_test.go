@@ -0,0 +1,60 @@
+package avatar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/urlsign"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+func TestHandlers_ResolveBatch(t *testing.T) {
+	asserts := assert.New(t)
+
+	conf := newTestConfig()
+	conf.Server.URLSigning.Enabled = true
+	conf.Server.Batch.Tokens = []string{"t0ken"}
+	svc := newFakeService(map[string]*avatar.Avatar{"known": testAvatar})
+	e := newTestEngine(handlers{Config: conf, AvatarService: svc, URLSigner: newTestURLSigner(t, conf)})
+
+	resolve := func(headers ...ut.Header) []ResolveBatchResult {
+		body := `{"hashes": ["known", "unknown"], "s": 120, "inline": true}`
+		headers = append(headers, ut.Header{Key: "Content-Type", Value: "application/json"})
+		resp := ut.PerformRequest(e, http.MethodPost, "/avatar/_batch", &ut.Body{Body: strings.NewReader(body), Len: len(body)}, headers...).Result()
+		asserts.Equal(http.StatusOK, resp.StatusCode())
+		asserts.Equal("no-store", resp.Header.Get("Cache-Control"))
+
+		var res ResolveBatchResponse
+		asserts.NoError(json.Unmarshal(resp.Body(), &res))
+		asserts.Len(res.Avatars, 2)
+		return res.Avatars
+	}
+
+	// anonymous callers, and callers with an unknown token, get unsigned urls and no data uris
+	for _, headers := range [][]ut.Header{nil, {{Key: "Authorization", Value: "Bearer wrong"}}} {
+		results := resolve(headers...)
+		asserts.True(results[0].Exists)
+		asserts.Equal("https://avatar.example.com/avatar/known?s=120", results[0].URL)
+		asserts.Empty(results[0].DataURI)
+		asserts.False(results[1].Exists)
+		asserts.Equal("https://avatar.example.com/avatar/unknown?s=120", results[1].URL)
+	}
+
+	// trusted callers get signed urls and the inlined avatars
+	results := resolve(ut.Header{Key: "Authorization", Value: "Bearer t0ken"})
+	for _, result := range results {
+		u, err := url.Parse(result.URL)
+		asserts.NoError(err)
+		asserts.Equal("/avatar/"+result.Hash, u.Path)
+		asserts.Equal("120", u.Query().Get("s"))
+		asserts.Equal("current", u.Query().Get(urlsign.ParamKeyID))
+	}
+	asserts.Equal("data:image/png;base64,cG5n", results[0].DataURI)
+	asserts.Empty(results[1].DataURI)
+}
@@ -1,15 +1,15 @@
 package controllers
 
 import (
+	"compress/gzip"
 	"context"
 	"github.com/AH-dark/gravatar-with-qq-avatar/server/middlewares"
 	"github.com/cloudwego/hertz/pkg/app/server"
-	"github.com/hertz-contrib/etag"
-	"github.com/hertz-contrib/gzip"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/fx"
 
 	"github.com/AH-dark/gravatar-with-qq-avatar/server/controllers/avatar"
+	"github.com/AH-dark/gravatar-with-qq-avatar/server/health"
 )
 
 var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/internal/server/controllers")
@@ -17,19 +17,52 @@ var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/internal/se
 type HandlerGroup struct {
 	fx.In
 	AvatarHandlers avatar.Handlers
+	HealthChecker  *health.Checker
 }
 
-func BindControllers(ctx context.Context, svr *server.Hertz, handlers HandlerGroup) {
+type MiddlewareGroup struct {
+	fx.In
+	RateLimiter          *middlewares.RateLimiter
+	URLSignatureVerifier *middlewares.URLSignatureVerifier
+	HotlinkProtector     *middlewares.HotlinkProtector
+}
+
+func BindControllers(ctx context.Context, svr *server.Hertz, handlers HandlerGroup, mws MiddlewareGroup) {
 	ctx, span := tracer.Start(ctx, "server.controllers.BindControllers")
 	defer span.End()
 
 	svr.Use(middlewares.RequestId())
 
+	svr.GET("/healthz", handlers.HealthChecker.Healthz)
+	svr.GET("/readyz", handlers.HealthChecker.Readyz)
+
 	avatarRouter := svr.Group("/avatar")
-	avatarRouter.Use(gzip.Gzip(gzip.BestCompression))
-	avatarRouter.Use(etag.New())
+	avatarRouter.Use(mws.URLSignatureVerifier.Middleware())
+	avatarRouter.Use(mws.HotlinkProtector.Middleware())
+	avatarRouter.Use(mws.RateLimiter.Middleware())
+	avatarRouter.Use(middlewares.Compress(gzip.BestCompression))
 	{
 		avatarRouter.GET("", handlers.AvatarHandlers.GetAvatar)
 		avatarRouter.GET("/:hash", handlers.AvatarHandlers.GetAvatar)
+		avatarRouter.HEAD("", handlers.AvatarHandlers.HeadAvatar)
+		avatarRouter.HEAD("/:hash", handlers.AvatarHandlers.HeadAvatar)
+		avatarRouter.GET("/:hash/placeholder", handlers.AvatarHandlers.GetPlaceholder)
+		avatarRouter.GET("/_montage", handlers.AvatarHandlers.GetMontage)
+	}
+
+	// batches are not embedded, so neither signed urls nor referers apply,
+	// instead they are charged per hash and hand out signed urls to callers with a batch token
+	batchRouter := svr.Group("/avatar/_batch")
+	batchRouter.Use(mws.RateLimiter.MiddlewareN(avatar.BatchCost))
+	batchRouter.Use(middlewares.Compress(gzip.BestCompression))
+	{
+		batchRouter.POST("", handlers.AvatarHandlers.ResolveBatch)
+	}
+
+	profileRouter := svr.Group("/")
+	profileRouter.Use(mws.RateLimiter.Middleware())
+	profileRouter.Use(middlewares.Compress(gzip.BestCompression))
+	{
+		profileRouter.GET("/:file", handlers.AvatarHandlers.GetProfile)
 	}
 }
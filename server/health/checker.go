@@ -0,0 +1,200 @@
+// Package health answers the liveness and readiness probes and drains the server before it stops.
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/redis/go-redis/v9"
+	"github.com/scylladb/gocqlx/v2"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/server/health")
+
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+	StatusFailed      = "failed"
+)
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Latency  string `json:"latency"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	// critical checks fail the readiness, the others only degrade it
+	critical bool
+	run      func(ctx context.Context) error
+}
+
+type CheckerParams struct {
+	fx.In
+	Config        *config.Config
+	Session       *gocqlx.Session
+	Redis         redis.UniversalClient
+	AvatarService avatar.Service
+}
+
+type Checker struct {
+	checks   []check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewChecker(p CheckerParams) *Checker {
+	c := &Checker{
+		timeout: p.Config.Server.Health.Timeout,
+		checks: []check{
+			{name: "cassandra", critical: true, run: func(ctx context.Context) error {
+				return p.Session.Session.Query("SELECT now() FROM system.local").WithContext(ctx).Exec()
+			}},
+			{name: "redis", critical: true, run: func(ctx context.Context) error {
+				return p.Redis.Ping(ctx).Err()
+			}},
+		},
+	}
+
+	c.checks = append(c.checks, upstreamChecks(p.AvatarService)...)
+
+	return c
+}
+
+// upstreamChecks judge the upstreams by their circuit breakers. A failing upstream is served from the
+// stale cache, taking every instance out of rotation would not help, it only degrades the readiness.
+// Once the breakers of both avatar upstreams are open no avatar can be resolved though, which fails it.
+func upstreamChecks(svc avatar.Service) []check {
+	var checks []check
+	for _, name := range []string{"qq", "gravatar", "gravatar_profile"} {
+		name := name
+		checks = append(checks, check{name: "upstream:" + name, run: func(ctx context.Context) error {
+			return svc.CheckUpstreams(ctx)[name]
+		}})
+	}
+
+	checks = append(checks, check{name: "upstreams", critical: true, run: func(ctx context.Context) error {
+		res := svc.CheckUpstreams(ctx)
+		if res["qq"] == nil || res["gravatar"] == nil {
+			return nil
+		}
+
+		return errors.Join(res["qq"], res["gravatar"])
+	}})
+
+	return checks
+}
+
+// Drain fails the readiness from now on, so the load balancer stops sending new requests.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check runs all checks concurrently, each one bounded by `server.health.timeout`.
+func (c *Checker) Check(ctx context.Context) Report {
+	ctx, span := tracer.Start(ctx, "server.health.Checker.Check")
+	defer span.End()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := chk.run(ctx)
+			res := CheckResult{Status: StatusOK, Critical: chk.critical, Latency: time.Since(start).String()}
+			if err != nil {
+				otelzap.L().Ctx(ctx).Warn("health check failed", zap.String("check", chk.name), zap.Error(err))
+				res.Status, res.Error = StatusFailed, err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[chk.name] = res
+			if err != nil && chk.critical {
+				report.Status = StatusUnavailable
+			} else if err != nil && report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		}(chk)
+	}
+	wg.Wait()
+
+	if c.draining.Load() {
+		report.Status = StatusDraining
+	}
+
+	return report
+}
+
+// Healthz is the liveness probe, it only tells the process serves requests, even while draining.
+func (c *Checker) Healthz(ctx context.Context, rc *app.RequestContext) {
+	rc.JSON(http.StatusOK, Report{Status: StatusOK})
+	rc.Header("Cache-Control", "no-store")
+}
+
+// Readyz is the readiness probe, it answers 503 when a critical dependency fails or the server drains.
+func (c *Checker) Readyz(ctx context.Context, rc *app.RequestContext) {
+	ctx, span := tracer.Start(ctx, "server.health.Checker.Readyz")
+	defer span.End()
+
+	report := c.Check(ctx)
+
+	code := http.StatusOK
+	if report.Status == StatusUnavailable || report.Status == StatusDraining {
+		code = http.StatusServiceUnavailable
+	}
+
+	rc.JSON(code, report)
+	rc.Header("Cache-Control", "no-store")
+}
+
+// RegisterDrain drains the servers before they stop: the readiness fails and new requests
+// keep being served for `server.shutdown.drain_delay`, until the load balancers noticed.
+// It must be invoked after the servers are run, as fx runs the stop hooks in reverse order.
+func RegisterDrain(conf *config.Config, checker *Checker, lc fx.Lifecycle) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			delay := conf.Server.Shutdown.DrainDelay
+			otelzap.L().Ctx(ctx).Info("draining before shutdown", zap.Duration("delay", delay))
+			checker.Drain()
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+
+			return nil
+		},
+	})
+}
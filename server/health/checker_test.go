@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	hertzconfig "github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxtest"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+// fakeService reports the upstream errors it holds, the methods a test doesn't need panic.
+type fakeService struct {
+	avatar.Service
+
+	mu        sync.Mutex
+	upstreams map[string]error
+}
+
+func (s *fakeService) CheckUpstreams(context.Context) map[string]error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make(map[string]error, len(s.upstreams))
+	for name, err := range s.upstreams {
+		res[name] = err
+	}
+
+	return res
+}
+
+func (s *fakeService) open(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		s.upstreams[name] = gobreaker.ErrOpenState
+	}
+}
+
+// newTestChecker checks a critical database failing with the returned error, and the upstreams of the service.
+func newTestChecker() (*Checker, *fakeService, *error) {
+	svc := &fakeService{upstreams: map[string]error{"qq": nil, "gravatar": nil, "gravatar_profile": nil}}
+	var dbErr error
+
+	c := &Checker{
+		timeout: time.Second,
+		checks: append([]check{
+			{name: "cassandra", critical: true, run: func(context.Context) error { return dbErr }},
+		}, upstreamChecks(svc)...),
+	}
+
+	return c, svc, &dbErr
+}
+
+func readyz(t *testing.T, c *Checker) (int, Report) {
+	e := route.NewEngine(hertzconfig.NewOptions(nil))
+	e.GET("/readyz", c.Readyz)
+	e.GET("/healthz", c.Healthz)
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/readyz", nil).Result()
+
+	var report Report
+	if err := json.Unmarshal(resp.Body(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	// the liveness never depends on the checks
+	if code := ut.PerformRequest(e, http.MethodGet, "/healthz", nil).Result().StatusCode(); code != http.StatusOK {
+		t.Errorf("healthz answered %d", code)
+	}
+
+	return resp.StatusCode(), report
+}
+
+func TestChecker_Readyz(t *testing.T) {
+	asserts := assert.New(t)
+
+	c, _, dbErr := newTestChecker()
+
+	code, report := readyz(t, c)
+	asserts.Equal(http.StatusOK, code)
+	asserts.Equal(StatusOK, report.Status)
+	asserts.Len(report.Checks, 5)
+	asserts.Equal(StatusOK, report.Checks["cassandra"].Status)
+	asserts.True(report.Checks["cassandra"].Critical)
+
+	*dbErr = errors.New("no hosts available")
+	code, report = readyz(t, c)
+	asserts.Equal(http.StatusServiceUnavailable, code)
+	asserts.Equal(StatusUnavailable, report.Status)
+	asserts.Equal(StatusFailed, report.Checks["cassandra"].Status)
+	asserts.Equal("no hosts available", report.Checks["cassandra"].Error)
+}
+
+func TestChecker_ReadyzUpstreams(t *testing.T) {
+	asserts := assert.New(t)
+
+	c, svc, _ := newTestChecker()
+
+	// one upstream is served from the stale cache, it only degrades the readiness
+	svc.open("qq")
+	code, report := readyz(t, c)
+	asserts.Equal(http.StatusOK, code)
+	asserts.Equal(StatusDegraded, report.Status)
+	asserts.Equal(StatusFailed, report.Checks["upstream:qq"].Status)
+	asserts.False(report.Checks["upstream:qq"].Critical)
+	asserts.Equal(StatusOK, report.Checks["upstreams"].Status)
+
+	// with the breakers of both avatar upstreams open nothing can be resolved
+	svc.open("gravatar")
+	code, report = readyz(t, c)
+	asserts.Equal(http.StatusServiceUnavailable, code)
+	asserts.Equal(StatusUnavailable, report.Status)
+	asserts.Equal(StatusFailed, report.Checks["upstreams"].Status)
+}
+
+func TestRegisterDrain(t *testing.T) {
+	asserts := assert.New(t)
+
+	c, _, _ := newTestChecker()
+	conf := &config.Config{}
+	conf.Server.Shutdown.DrainDelay = 50 * time.Millisecond
+
+	lc := fxtest.NewLifecycle(t)
+	RegisterDrain(conf, c, lc)
+	lc.RequireStart()
+
+	code, _ := readyz(t, c)
+	asserts.Equal(http.StatusOK, code)
+
+	// the stop hook fails the readiness and waits for the drain delay
+	start := time.Now()
+	lc.RequireStop()
+	asserts.GreaterOrEqual(time.Since(start), conf.Server.Shutdown.DrainDelay)
+
+	code, report := readyz(t, c)
+	asserts.Equal(http.StatusServiceUnavailable, code)
+	asserts.Equal(StatusDraining, report.Status)
+}
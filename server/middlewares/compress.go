@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/AH-dark/bytestring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/compress"
+)
+
+// Compress gzips the response once the handler wrote it. Unlike gzip.Gzip it decides by the
+// content type of the response, so images, which are compressed already, are sent byte for byte
+// and their strong ETags stay valid.
+func Compress(level int) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		ctx, span := tracer.Start(ctx, "server.middlewares.Compress")
+		defer span.End()
+
+		c.Next(ctx)
+
+		if strings.HasPrefix(bytestring.BytesToString(c.Response.Header.ContentType()), "image/") ||
+			len(c.Response.Header.Peek("Content-Encoding")) > 0 {
+			return
+		}
+
+		c.Response.Header.Add("Vary", "Accept-Encoding")
+		if len(c.Response.Body()) == 0 || !acceptsGzip(bytestring.BytesToString(c.GetHeader("Accept-Encoding"))) {
+			return
+		}
+
+		body := compress.AppendGzipBytesLevel(nil, c.Response.Body(), level)
+		c.Response.SetBodyStream(bytes.NewBuffer(body), len(body))
+		c.Header("Content-Encoding", "gzip")
+	}
+}
+
+func acceptsGzip(header string) bool {
+	for _, item := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+
+	return false
+}
@@ -0,0 +1,261 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AH-dark/bytestring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+const (
+	HotlinkActionForbid   = "forbid"
+	HotlinkActionDefault  = "default"
+	HotlinkActionRedirect = "redirect"
+)
+
+// HotlinkProtector only lets pages of the allowed hosts embed avatars, based on the Origin or Referer header.
+type HotlinkProtector struct {
+	// config is replaced as a whole when the config file changes
+	config atomic.Pointer[hotlinkConfig]
+
+	rejected *prometheus.CounterVec
+	// origins are the registrable domains labelled in the rejected metric, other domains share a bucket
+	originsMu sync.Mutex
+	origins   map[string]struct{}
+}
+
+// hotlinkOriginLabels caps the number of domains labelled in the rejected metric.
+const hotlinkOriginLabels = 50
+
+const (
+	hotlinkOriginEmpty   = "empty"
+	hotlinkOriginInvalid = "invalid"
+	hotlinkOriginOther   = "other"
+)
+
+type hotlinkConfig struct {
+	enabled           bool
+	allowed           []string
+	allowEmptyReferer bool
+	action            string
+	redirectURL       string
+	defaultImage      []byte
+}
+
+func NewHotlinkProtector(ctx context.Context, conf *config.Config, registry *prometheus.Registry, reloader *config.Reloader) (*HotlinkProtector, error) {
+	ctx, span := tracer.Start(ctx, "server.middlewares.NewHotlinkProtector")
+	defer span.End()
+
+	cfg, err := newHotlinkConfig(conf.Server.Hotlink)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("invalid hotlink config", zap.Error(err))
+		return nil, err
+	}
+
+	p := &HotlinkProtector{
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hotlink_rejected_requests_total",
+			Help: "Number of avatar requests rejected by the hotlink protection, by the registrable domain of the origin, empty, invalid or other once too many domains were seen.",
+		}, []string{"origin"}),
+		origins: make(map[string]struct{}),
+	}
+	p.config.Store(cfg)
+
+	// registered even when disabled, the protection may be enabled by a config change
+	if err := registry.Register(p.rejected); err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("register hotlink metrics failed", zap.Error(err))
+		return nil, err
+	}
+
+	reloader.Subscribe("hotlink protector", []string{"server.hotlink"}, func(ctx context.Context, event config.ChangeEvent) (func(), error) {
+		cfg, err := newHotlinkConfig(event.Config.Server.Hotlink)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { p.config.Store(cfg) }, nil
+	})
+
+	return p, nil
+}
+
+func newHotlinkConfig(conf config.HotlinkConfig) (*hotlinkConfig, error) {
+	cfg := &hotlinkConfig{
+		enabled:           conf.Enabled,
+		allowEmptyReferer: conf.AllowEmptyReferer,
+		action:            conf.Action,
+		redirectURL:       conf.RedirectURL,
+	}
+
+	if !cfg.enabled {
+		return cfg, nil
+	}
+
+	for _, pattern := range conf.Allowed {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid hotlink pattern %q: %w", pattern, err)
+		}
+
+		cfg.allowed = append(cfg.allowed, pattern)
+	}
+
+	switch cfg.action {
+	case HotlinkActionForbid:
+	case HotlinkActionRedirect:
+		if cfg.redirectURL == "" {
+			return nil, fmt.Errorf("server.hotlink.redirect_url is required for the %s action", cfg.action)
+		}
+	case HotlinkActionDefault:
+		img, err := loadHotlinkImage(conf.DefaultImage)
+		if err != nil {
+			return nil, fmt.Errorf("load hotlink default image: %w", err)
+		}
+
+		cfg.defaultImage = img
+	default:
+		return nil, fmt.Errorf("hotlink action %s not supported", cfg.action)
+	}
+
+	return cfg, nil
+}
+
+// loadHotlinkImage reads the configured png, or renders a plain gray square without one.
+func loadHotlinkImage(filename string) ([]byte, error) {
+	if filename != "" {
+		return os.ReadFile(filename)
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 80, 80))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 0xc8}), image.Point{}, draw.Src)
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// origin returns the host of the page embedding the avatar, Origin takes precedence over Referer.
+func origin(c *app.RequestContext) (string, bool) {
+	header := bytestring.BytesToString(c.GetHeader("Origin"))
+	if header == "" || header == "null" {
+		header = bytestring.BytesToString(c.GetHeader("Referer"))
+	}
+
+	if header == "" {
+		return "", true
+	}
+
+	u, err := url.Parse(header)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+
+	return strings.ToLower(u.Hostname()), true
+}
+
+func (cfg *hotlinkConfig) isAllowed(host string) bool {
+	for _, pattern := range cfg.allowed {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// originLabel returns the registrable domain of the host as long as fewer than hotlinkOriginLabels
+// domains were labelled, so the metric stays bounded however many pages embed avatars.
+func (p *HotlinkProtector) originLabel(host string) string {
+	// ip addresses and bare public suffixes have no registrable domain
+	if net.ParseIP(host) != nil {
+		return hotlinkOriginOther
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return hotlinkOriginOther
+	}
+
+	p.originsMu.Lock()
+	defer p.originsMu.Unlock()
+
+	if _, ok := p.origins[domain]; ok {
+		return domain
+	}
+	if len(p.origins) >= hotlinkOriginLabels {
+		return hotlinkOriginOther
+	}
+
+	p.origins[domain] = struct{}{}
+	return domain
+}
+
+func (p *HotlinkProtector) Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		cfg := p.config.Load()
+		if !cfg.enabled {
+			c.Next(ctx)
+			return
+		}
+
+		ctx, span := tracer.Start(ctx, "server.middlewares.Hotlink")
+		defer span.End()
+
+		host, ok := origin(c)
+		var label string
+		switch {
+		case !ok:
+			label = hotlinkOriginInvalid
+		case host == "" && cfg.allowEmptyReferer, host != "" && cfg.isAllowed(host):
+			c.Next(ctx)
+			// shared caches must not answer another page with the response allowed for this one
+			c.Response.Header.Add("Vary", "Origin, Referer")
+			return
+		case host == "":
+			label = hotlinkOriginEmpty
+		default:
+			label = p.originLabel(host)
+		}
+
+		p.rejected.WithLabelValues(label).Inc()
+		otelzap.L().Ctx(ctx).Debug("hotlink rejected", zap.String("origin", host), zap.String("label", label))
+
+		// the answer depends on the referer, so it must not end up in shared caches
+		c.Header("Cache-Control", "private, no-store")
+
+		switch cfg.action {
+		case HotlinkActionRedirect:
+			c.Redirect(http.StatusFound, bytestring.StringToBytes(cfg.redirectURL))
+			c.Abort()
+		case HotlinkActionDefault:
+			c.Data(http.StatusOK, http.DetectContentType(cfg.defaultImage), cfg.defaultImage)
+			c.Abort()
+		default:
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+func newTestHotlinkProtector(t *testing.T, conf config.HotlinkConfig) *HotlinkProtector {
+	cfg, err := newHotlinkConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &HotlinkProtector{
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "hotlink_rejected_requests_total"}, []string{"origin"}),
+		origins:  make(map[string]struct{}),
+	}
+	p.config.Store(cfg)
+
+	return p
+}
+
+var testHotlinkConfig = config.HotlinkConfig{
+	Enabled:           true,
+	Allowed:           []string{"example.com", "*.example.com"},
+	AllowEmptyReferer: true,
+	Action:            HotlinkActionForbid,
+}
+
+func TestHotlinkProtector_Middleware(t *testing.T) {
+	asserts := assert.New(t)
+
+	p := newTestHotlinkProtector(t, testHotlinkConfig)
+	e := newTestEngine(p.Middleware())
+
+	for _, header := range []ut.Header{
+		{Key: "Origin", Value: "https://example.com"},
+		{Key: "Referer", Value: "https://blog.example.com/post"},
+		{Key: "Origin", Value: "null"},
+	} {
+		resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil, header).Result()
+		asserts.Equal(http.StatusOK, resp.StatusCode(), header.Value)
+		asserts.Equal("Origin, Referer", resp.Header.Get("Vary"), header.Value)
+	}
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil,
+		ut.Header{Key: "Referer", Value: "https://evil.co.uk/page"}).Result()
+	asserts.Equal(http.StatusForbidden, resp.StatusCode())
+	asserts.Equal("private, no-store", resp.Header.Get("Cache-Control"))
+
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil,
+		ut.Header{Key: "Referer", Value: "https://www.evil.co.uk/other"}).Result()
+	asserts.Equal(http.StatusForbidden, resp.StatusCode())
+
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil,
+		ut.Header{Key: "Referer", Value: "::"}).Result()
+	asserts.Equal(http.StatusForbidden, resp.StatusCode())
+
+	// hosts are labelled by their registrable domain
+	asserts.Equal(float64(2), testutil.ToFloat64(p.rejected.WithLabelValues("evil.co.uk")))
+	asserts.Equal(float64(1), testutil.ToFloat64(p.rejected.WithLabelValues(hotlinkOriginInvalid)))
+}
+
+func TestHotlinkProtector_EmptyReferer(t *testing.T) {
+	asserts := assert.New(t)
+
+	conf := testHotlinkConfig
+	conf.AllowEmptyReferer = false
+	conf.Action = HotlinkActionRedirect
+	conf.RedirectURL = "https://example.com/hotlink.png"
+	p := newTestHotlinkProtector(t, conf)
+	e := newTestEngine(p.Middleware())
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil).Result()
+	asserts.Equal(http.StatusFound, resp.StatusCode())
+	asserts.Equal(conf.RedirectURL, resp.Header.Get("Location"))
+	asserts.Equal(float64(1), testutil.ToFloat64(p.rejected.WithLabelValues(hotlinkOriginEmpty)))
+}
+
+func TestHotlinkProtector_OriginLabel(t *testing.T) {
+	asserts := assert.New(t)
+
+	p := newTestHotlinkProtector(t, testHotlinkConfig)
+	asserts.Equal(hotlinkOriginOther, p.originLabel("127.0.0.1"))
+	asserts.Equal(hotlinkOriginOther, p.originLabel("co.uk"))
+
+	for i := 0; i < hotlinkOriginLabels; i++ {
+		asserts.Equal(fmt.Sprintf("site%d.com", i), p.originLabel(fmt.Sprintf("www.site%d.com", i)))
+	}
+
+	// once the labels are used up new domains share a bucket, known ones keep theirs
+	asserts.Equal(hotlinkOriginOther, p.originLabel("new.com"))
+	asserts.Equal("site0.com", p.originLabel("cdn.site0.com"))
+}
+
+func TestHotlinkProtector_Disabled(t *testing.T) {
+	asserts := assert.New(t)
+
+	p := newTestHotlinkProtector(t, config.HotlinkConfig{})
+	e := newTestEngine(p.Middleware())
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil,
+		ut.Header{Key: "Referer", Value: "https://evil.com"}).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+	asserts.Empty(resp.Header.Get("Vary"))
+}
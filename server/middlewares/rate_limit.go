@@ -0,0 +1,197 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/go-redis/redis_rate/v10"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/ratelimit"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+type RateLimiter struct {
+	remote *redis_rate.Limiter
+	local  *ratelimit.Limiter
+
+	// remoteDisabledUntil holds the unix nano timestamp until which the redis limiter is skipped
+	// after it failed, so that a redis outage doesn't add a dial timeout to every request.
+	remoteDisabledUntil atomic.Int64
+
+	// config is replaced as a whole when the config file changes
+	config atomic.Pointer[rateLimitConfig]
+}
+
+type rateLimitConfig struct {
+	enabled          bool
+	fallbackCooldown time.Duration
+
+	ipLimit      redis_rate.Limit
+	apiKeyHeader string
+	apiKeys      map[string]config.APIKeyLimitConfig
+}
+
+func NewRateLimiter(ctx context.Context, conf *config.Config, limiter *redis_rate.Limiter, reloader *config.Reloader) (*RateLimiter, error) {
+	ctx, span := tracer.Start(ctx, "server.middlewares.NewRateLimiter")
+	defer span.End()
+
+	cfg, err := newRateLimitConfig(conf.Server.RateLimit)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("invalid rate limit config", zap.Error(err))
+		return nil, err
+	}
+
+	l := &RateLimiter{
+		remote: limiter,
+		local:  ratelimit.NewLimiter(),
+	}
+	l.config.Store(cfg)
+
+	reloader.Subscribe("rate limiter", []string{"server.rate_limit"}, func(ctx context.Context, event config.ChangeEvent) (func(), error) {
+		cfg, err := newRateLimitConfig(event.Config.Server.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { l.config.Store(cfg) }, nil
+	})
+
+	return l, nil
+}
+
+func newRateLimitConfig(conf config.RateLimitConfig) (*rateLimitConfig, error) {
+	cfg := &rateLimitConfig{
+		enabled:          conf.Enabled,
+		fallbackCooldown: conf.FallbackCooldown,
+		ipLimit:          limitOf(conf.IP),
+		apiKeyHeader:     conf.APIKey.Header,
+		apiKeys:          make(map[string]config.APIKeyLimitConfig),
+	}
+
+	if !cfg.enabled {
+		return cfg, nil
+	}
+
+	if err := validateLimit(cfg.ipLimit); err != nil {
+		return nil, fmt.Errorf("invalid ip rate limit: %w", err)
+	}
+
+	for _, key := range conf.APIKey.Keys {
+		if err := validateLimit(limitOf(key.LimitConfig)); err != nil {
+			return nil, fmt.Errorf("invalid rate limit of api key %s: %w", key.Name, err)
+		}
+
+		cfg.apiKeys[key.Key] = key
+	}
+
+	return cfg, nil
+}
+
+func limitOf(limit config.LimitConfig) redis_rate.Limit {
+	return redis_rate.Limit{
+		Rate:   limit.Rate,
+		Burst:  limit.Burst,
+		Period: limit.Period,
+	}
+}
+
+func validateLimit(limit redis_rate.Limit) error {
+	if limit.Rate <= 0 || limit.Burst <= 0 || limit.Period <= 0 {
+		return fmt.Errorf("rate, burst and period must be positive, got %s with burst %d", limit, limit.Burst)
+	}
+
+	return nil
+}
+
+// Middleware limits requests per api key when a known key is presented, otherwise per client ip.
+func (l *RateLimiter) Middleware() app.HandlerFunc {
+	return l.MiddlewareN(func(*app.RequestContext) int { return 1 })
+}
+
+// MiddlewareN limits like Middleware, charging each request the number of tokens cost returns,
+// for requests doing the work of many. A request costing more than the burst is always rejected.
+func (l *RateLimiter) MiddlewareN(cost func(c *app.RequestContext) int) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		cfg := l.config.Load()
+		if !cfg.enabled {
+			c.Next(ctx)
+			return
+		}
+
+		ctx, span := tracer.Start(ctx, "server.middlewares.RateLimit")
+		defer span.End()
+
+		key, limit := "rate_limit:ip:"+c.ClientIP(), cfg.ipLimit
+		if cfg.apiKeyHeader != "" {
+			if apiKey, ok := cfg.apiKeys[string(c.GetHeader(cfg.apiKeyHeader))]; ok {
+				key, limit = "rate_limit:key:"+apiKey.Name, limitOf(apiKey.LimitConfig)
+			}
+		}
+
+		n := max(cost(c), 1)
+		if n > limit.Burst {
+			c.AbortWithMsg(fmt.Sprintf("request costs %d rate limit tokens, more than the burst of %d", n, limit.Burst), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		res, err := l.allow(ctx, cfg, key, limit, n)
+		if err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("rate limit failed", zap.Error(err))
+			c.Next(ctx)
+			return
+		}
+
+		c.Header(HeaderRateLimitLimit, strconv.Itoa(limit.Rate))
+		c.Header(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+		c.Header(HeaderRateLimitReset, strconv.FormatInt(ceilSeconds(res.ResetAfter), 10))
+		c.Header(HeaderRateLimitPolicy, fmt.Sprintf("%d;w=%d;burst=%d", limit.Rate, ceilSeconds(limit.Period), limit.Burst))
+
+		if res.Allowed == 0 {
+			c.Header(HeaderRetryAfter, strconv.FormatInt(ceilSeconds(res.RetryAfter), 10))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+
+		c.Next(ctx)
+	}
+}
+
+func (l *RateLimiter) allow(ctx context.Context, cfg *rateLimitConfig, key string, limit redis_rate.Limit, n int) (*redis_rate.Result, error) {
+	if time.Now().UnixNano() >= l.remoteDisabledUntil.Load() {
+		res, err := l.remote.AllowN(ctx, key, limit, n)
+		if err == nil {
+			return res, nil
+		}
+
+		otelzap.L().Ctx(ctx).Warn("redis rate limiter unavailable, falling back to local limiter", zap.Error(err))
+		l.remoteDisabledUntil.Store(time.Now().Add(cfg.fallbackCooldown).UnixNano())
+	}
+
+	return l.local.AllowN(ctx, key, limit, n)
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+
+	return int64(math.Ceil(d.Seconds()))
+}
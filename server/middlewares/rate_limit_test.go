@@ -0,0 +1,144 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudwego/hertz/pkg/app"
+	hertzconfig "github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/go-redis/redis_rate/v10"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/ratelimit"
+)
+
+// newTestEngine routes GET /avatar/:hash and POST /batch through the middlewares to a handler answering 200.
+func newTestEngine(mws ...app.HandlerFunc) *route.Engine {
+	e := route.NewEngine(hertzconfig.NewOptions(nil))
+	e.Use(mws...)
+	ok := func(ctx context.Context, c *app.RequestContext) { c.String(http.StatusOK, "ok") }
+	e.GET("/avatar/:hash", ok)
+	e.POST("/batch", ok)
+	return e
+}
+
+func newTestRateLimiter(t *testing.T, conf config.RateLimitConfig) (*RateLimiter, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	cfg, err := newRateLimitConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &RateLimiter{
+		remote: redis_rate.NewLimiter(rdb),
+		local:  ratelimit.NewLimiter(),
+	}
+	l.config.Store(cfg)
+
+	return l, mr
+}
+
+var testRateLimitConfig = config.RateLimitConfig{
+	Enabled:          true,
+	FallbackCooldown: time.Minute,
+	IP:               config.LimitConfig{Rate: 2, Burst: 2, Period: time.Minute},
+	APIKey: config.APIKeyConfig{
+		Header: "X-API-Key",
+		Keys: []config.APIKeyLimitConfig{
+			{Name: "partner", Key: "secret", LimitConfig: config.LimitConfig{Rate: 10, Burst: 10, Period: time.Minute}},
+		},
+	},
+}
+
+func TestRateLimiter_Headers(t *testing.T) {
+	asserts := assert.New(t)
+
+	l, _ := newTestRateLimiter(t, testRateLimitConfig)
+	e := newTestEngine(l.Middleware())
+
+	for i := 1; i >= 0; i-- {
+		resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil).Result()
+		asserts.Equal(http.StatusOK, resp.StatusCode())
+		asserts.Equal("2", resp.Header.Get(HeaderRateLimitLimit))
+		asserts.Equal(strconv.Itoa(i), resp.Header.Get(HeaderRateLimitRemaining))
+		asserts.NotEmpty(resp.Header.Get(HeaderRateLimitReset))
+		asserts.Equal("2;w=60;burst=2", resp.Header.Get(HeaderRateLimitPolicy))
+		asserts.Empty(resp.Header.Get(HeaderRetryAfter))
+	}
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil).Result()
+	asserts.Equal(http.StatusTooManyRequests, resp.StatusCode())
+	asserts.Equal("0", resp.Header.Get(HeaderRateLimitRemaining))
+	asserts.Equal("30", resp.Header.Get(HeaderRetryAfter))
+
+	// a known api key has a bucket and limit of its own
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil, ut.Header{Key: "X-API-Key", Value: "secret"}).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+	asserts.Equal("10", resp.Header.Get(HeaderRateLimitLimit))
+	asserts.Equal("9", resp.Header.Get(HeaderRateLimitRemaining))
+}
+
+func TestRateLimiter_MiddlewareN(t *testing.T) {
+	asserts := assert.New(t)
+
+	l, _ := newTestRateLimiter(t, testRateLimitConfig)
+	cost := 0
+	e := newTestEngine(l.MiddlewareN(func(*app.RequestContext) int { return cost }))
+	key := ut.Header{Key: "X-API-Key", Value: "secret"}
+
+	// every request costs at least one token
+	resp := ut.PerformRequest(e, http.MethodPost, "/batch", nil, key).Result()
+	asserts.Equal("9", resp.Header.Get(HeaderRateLimitRemaining))
+
+	cost = 6
+	resp = ut.PerformRequest(e, http.MethodPost, "/batch", nil, key).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+	asserts.Equal("3", resp.Header.Get(HeaderRateLimitRemaining))
+
+	cost = 4
+	resp = ut.PerformRequest(e, http.MethodPost, "/batch", nil, key).Result()
+	asserts.Equal(http.StatusTooManyRequests, resp.StatusCode())
+	asserts.NotEmpty(resp.Header.Get(HeaderRetryAfter))
+
+	// requests costing more than the burst can never succeed
+	cost = 11
+	resp = ut.PerformRequest(e, http.MethodPost, "/batch", nil, key).Result()
+	asserts.Equal(http.StatusRequestEntityTooLarge, resp.StatusCode())
+}
+
+func TestRateLimiter_LocalFallback(t *testing.T) {
+	asserts := assert.New(t)
+
+	l, mr := newTestRateLimiter(t, testRateLimitConfig)
+	e := newTestEngine(l.Middleware())
+
+	mr.SetError("redis unavailable")
+
+	// the local limiter takes over and still limits
+	for i := 1; i >= 0; i-- {
+		resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil).Result()
+		asserts.Equal(http.StatusOK, resp.StatusCode())
+		asserts.Equal(strconv.Itoa(i), resp.Header.Get(HeaderRateLimitRemaining))
+	}
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil).Result()
+	asserts.Equal(http.StatusTooManyRequests, resp.StatusCode())
+
+	// redis is skipped for the cooldown once it failed
+	asserts.Greater(l.remoteDisabledUntil.Load(), time.Now().UnixNano())
+	mr.SetError("")
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/hash", nil).Result()
+	asserts.Equal(http.StatusTooManyRequests, resp.StatusCode())
+	asserts.Empty(mr.Keys())
+}
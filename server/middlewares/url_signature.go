@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AH-dark/bytestring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/urlsign"
+)
+
+// URLSignatureVerifier rejects avatar requests without a valid signature when url signing is enabled,
+// so third parties cannot use the service as a free avatar proxy.
+type URLSignatureVerifier struct {
+	enabled bool
+	signer  *urlsign.Signer
+	// keyID is the first configured key, which signs the urls the service hands out itself
+	keyID string
+}
+
+func NewURLSignatureVerifier(ctx context.Context, conf *config.Config) (*URLSignatureVerifier, error) {
+	ctx, span := tracer.Start(ctx, "server.middlewares.NewURLSignatureVerifier")
+	defer span.End()
+
+	v := &URLSignatureVerifier{
+		enabled: conf.Server.URLSigning.Enabled,
+	}
+
+	if !v.enabled {
+		return v, nil
+	}
+
+	secrets := make(map[string]string, len(conf.Server.URLSigning.Keys))
+	for _, key := range conf.Server.URLSigning.Keys {
+		secrets[key.ID] = key.Secret
+	}
+
+	signer, err := urlsign.NewSigner(secrets)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("invalid url signing keys", zap.Error(err))
+		return nil, err
+	}
+
+	v.signer = signer
+	if len(conf.Server.URLSigning.Keys) > 0 {
+		v.keyID = conf.Server.URLSigning.Keys[0].ID
+	}
+	return v, nil
+}
+
+// Sign signs the path and query with the first key when url signing is enabled, the query is returned as is otherwise.
+// A zero expires signs a url that never expires.
+func (v *URLSignatureVerifier) Sign(path string, query url.Values, expires time.Time) (url.Values, error) {
+	if !v.enabled {
+		return query, nil
+	}
+
+	return v.signer.Sign(v.keyID, path, query, expires)
+}
+
+func (v *URLSignatureVerifier) Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if !v.enabled {
+			c.Next(ctx)
+			return
+		}
+
+		ctx, span := tracer.Start(ctx, "server.middlewares.URLSignature")
+		defer span.End()
+
+		query, err := url.ParseQuery(bytestring.BytesToString(c.URI().QueryString()))
+		if err == nil {
+			err = v.signer.Verify(bytestring.BytesToString(c.URI().Path()), query)
+		}
+		if err != nil {
+			otelzap.L().Ctx(ctx).Debug("url signature rejected", zap.Error(err))
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Next(ctx)
+	}
+}
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/urlsign"
+)
+
+func newTestURLSignatureVerifier(t *testing.T, enabled bool) *URLSignatureVerifier {
+	conf := &config.Config{}
+	conf.Server.URLSigning = config.URLSigningConfig{
+		Enabled: enabled,
+		Keys:    []config.SigningKeyConfig{{ID: "current", Secret: "s3cret"}, {ID: "previous", Secret: "0ld"}},
+	}
+
+	v, err := NewURLSignatureVerifier(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestURLSignatureVerifier_Middleware(t *testing.T) {
+	asserts := assert.New(t)
+
+	v := newTestURLSignatureVerifier(t, true)
+	e := newTestEngine(v.Middleware())
+
+	query, err := v.Sign("/avatar/hash", map[string][]string{"s": {"80"}}, time.Time{})
+	asserts.NoError(err)
+	asserts.Equal("current", query.Get(urlsign.ParamKeyID))
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash?"+query.Encode(), nil).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+
+	// unsigned, tampered and expired urls are rejected
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/hash?s=80", nil).Result()
+	asserts.Equal(http.StatusForbidden, resp.StatusCode())
+
+	query.Set("s", "640")
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/hash?"+query.Encode(), nil).Result()
+	asserts.Equal(http.StatusForbidden, resp.StatusCode())
+
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/other?"+query.Encode(), nil).Result()
+	asserts.Equal(http.StatusForbidden, resp.StatusCode())
+
+	query, err = v.Sign("/avatar/hash", nil, time.Now().Add(-time.Minute))
+	asserts.NoError(err)
+	resp = ut.PerformRequest(e, http.MethodGet, "/avatar/hash?"+query.Encode(), nil).Result()
+	asserts.Equal(http.StatusForbidden, resp.StatusCode())
+}
+
+func TestURLSignatureVerifier_Disabled(t *testing.T) {
+	asserts := assert.New(t)
+
+	v := newTestURLSignatureVerifier(t, false)
+	e := newTestEngine(v.Middleware())
+
+	query, err := v.Sign("/avatar/hash", map[string][]string{"s": {"80"}}, time.Time{})
+	asserts.NoError(err)
+	asserts.Equal("s=80", query.Encode())
+
+	resp := ut.PerformRequest(e, http.MethodGet, "/avatar/hash?s=80", nil).Result()
+	asserts.Equal(http.StatusOK, resp.StatusCode())
+}
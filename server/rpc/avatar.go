@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	avatarv1 "github.com/AH-dark/gravatar-with-qq-avatar/api/avatar/v1"
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+const (
+	defaultSize = 80
+	maxSize     = 2048
+)
+
+var sources = map[avatar.Source]avatarv1.Source{
+	avatar.SourceQQ:       avatarv1.Source_SOURCE_QQ,
+	avatar.SourceGravatar: avatarv1.Source_SOURCE_GRAVATAR,
+	avatar.SourceDefault:  avatarv1.Source_SOURCE_DEFAULT,
+}
+
+type AvatarServerParams struct {
+	fx.In
+	Config        *config.Config
+	AvatarService avatar.Service
+}
+
+type avatarServer struct {
+	avatarv1.UnimplementedAvatarServiceServer
+
+	avatarService  avatar.Service
+	maxBatchHashes int
+	batchWorkers   int
+}
+
+func NewAvatarServer(p AvatarServerParams) avatarv1.AvatarServiceServer {
+	return &avatarServer{
+		avatarService:  p.AvatarService,
+		maxBatchHashes: p.Config.GRPC.Batch.MaxHashes,
+		batchWorkers:   max(p.Config.GRPC.Batch.Workers, 1),
+	}
+}
+
+func args(opts *avatarv1.AvatarOptions) (avatar.GetAvatarArgs, error) {
+	args := avatar.GetAvatarArgs{
+		Size:         opts.GetSize(),
+		Default:      opts.GetDefault(),
+		ForceDefault: opts.GetForceDefault(),
+		Rating:       opts.GetRating(),
+		EnableWebp:   opts.GetFormat() == avatarv1.Format_FORMAT_WEBP,
+	}
+
+	if args.Size == 0 {
+		args.Size = defaultSize
+	} else if args.Size < 0 || args.Size > maxSize {
+		return args, status.Errorf(codes.InvalidArgument, "size must be between 1 and %d, got %d", maxSize, args.Size)
+	}
+
+	return args, nil
+}
+
+func metadata(hash string, res *avatar.Avatar) *avatarv1.AvatarMetadata {
+	if res == nil {
+		return &avatarv1.AvatarMetadata{Hash: hash}
+	}
+
+	m := &avatarv1.AvatarMetadata{
+		Hash:         hash,
+		Found:        true,
+		Source:       sources[res.Source],
+		ContentType:  res.ContentType,
+		ContentHash:  res.ContentHash,
+		Etag:         res.ETag,
+		Width:        int32(res.Width),
+		Height:       int32(res.Height),
+		NativeWidth:  int32(res.NativeWidth),
+		NativeHeight: int32(res.NativeHeight),
+	}
+	if !res.LastModified.IsZero() {
+		m.LastModified = timestamppb.New(res.LastModified)
+	}
+
+	return m
+}
+
+// getAvatar maps the errors of the avatar service to grpc status errors.
+func (s *avatarServer) getAvatar(ctx context.Context, hash string, args avatar.GetAvatarArgs) (*avatar.Avatar, error) {
+	ctx, span := tracer.Start(ctx, "server.rpc.avatarServer.getAvatar")
+	defer span.End()
+
+	if hash == "" {
+		return nil, status.Error(codes.InvalidArgument, "hash must not be empty")
+	}
+
+	res, err := s.avatarService.GetAvatar(ctx, hash, args)
+	if errors.Is(err, avatar.ErrUpscaleRejected) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("get avatar data failed", zap.String("hash", hash), zap.Error(err))
+		return nil, status.Error(codes.Internal, "get avatar failed")
+	}
+
+	return res, nil
+}
+
+func (s *avatarServer) GetAvatar(ctx context.Context, req *avatarv1.GetAvatarRequest) (*avatarv1.GetAvatarResponse, error) {
+	ctx, span := tracer.Start(ctx, "server.rpc.avatarServer.GetAvatar")
+	defer span.End()
+
+	args, err := args(req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.getAvatar(ctx, req.GetHash(), args)
+	if err != nil {
+		return nil, err
+	} else if res == nil {
+		return nil, status.Errorf(codes.NotFound, "no avatar for %s", req.GetHash())
+	}
+
+	return &avatarv1.GetAvatarResponse{Metadata: metadata(req.GetHash(), res), Data: res.Data}, nil
+}
+
+func (s *avatarServer) ResolveAvatar(ctx context.Context, req *avatarv1.ResolveAvatarRequest) (*avatarv1.ResolveAvatarResponse, error) {
+	ctx, span := tracer.Start(ctx, "server.rpc.avatarServer.ResolveAvatar")
+	defer span.End()
+
+	args, err := args(req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.getAvatar(ctx, req.GetHash(), args)
+	if err != nil {
+		return nil, err
+	} else if res == nil {
+		return nil, status.Errorf(codes.NotFound, "no avatar for %s", req.GetHash())
+	}
+
+	return &avatarv1.ResolveAvatarResponse{Metadata: metadata(req.GetHash(), res)}, nil
+}
+
+func (s *avatarServer) BatchGetAvatars(ctx context.Context, req *avatarv1.BatchGetAvatarsRequest) (*avatarv1.BatchGetAvatarsResponse, error) {
+	ctx, span := tracer.Start(ctx, "server.rpc.avatarServer.BatchGetAvatars")
+	defer span.End()
+
+	avatars, errs, err := s.batch(ctx, req.GetHashes(), req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	res := &avatarv1.BatchGetAvatarsResponse{Avatars: make([]*avatarv1.GetAvatarResponse, len(avatars))}
+	for i, a := range avatars {
+		res.Avatars[i] = &avatarv1.GetAvatarResponse{Metadata: metadata(req.GetHashes()[i], a), Error: errs[i]}
+		if a != nil {
+			res.Avatars[i].Data = a.Data
+		}
+	}
+
+	return res, nil
+}
+
+func (s *avatarServer) BatchResolveAvatars(ctx context.Context, req *avatarv1.BatchResolveAvatarsRequest) (*avatarv1.BatchResolveAvatarsResponse, error) {
+	ctx, span := tracer.Start(ctx, "server.rpc.avatarServer.BatchResolveAvatars")
+	defer span.End()
+
+	avatars, errs, err := s.batch(ctx, req.GetHashes(), req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	res := &avatarv1.BatchResolveAvatarsResponse{Avatars: make([]*avatarv1.ResolveAvatarResponse, len(avatars))}
+	for i, a := range avatars {
+		res.Avatars[i] = &avatarv1.ResolveAvatarResponse{Metadata: metadata(req.GetHashes()[i], a), Error: errs[i]}
+	}
+
+	return res, nil
+}
+
+// itemError reports the status a hash of a batch failed with, nil when it didn't.
+func itemError(err error) *avatarv1.ItemError {
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(err)
+	return &avatarv1.ItemError{Code: int32(st.Code()), Message: st.Message()}
+}
+
+// batch resolves the hashes on `grpc.batch.workers` goroutines. A failing hash only fails its own entry,
+// only a malformed request fails the whole batch.
+func (s *avatarServer) batch(ctx context.Context, hashes []string, opts *avatarv1.AvatarOptions) ([]*avatar.Avatar, []*avatarv1.ItemError, error) {
+	ctx, span := tracer.Start(ctx, "server.rpc.avatarServer.batch")
+	defer span.End()
+
+	if len(hashes) == 0 || len(hashes) > s.maxBatchHashes {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "hashes must have between 1 and %d entries, got %d", s.maxBatchHashes, len(hashes))
+	}
+
+	args, err := args(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	avatars := make([]*avatar.Avatar, len(hashes))
+	errs := make([]*avatarv1.ItemError, len(hashes))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(s.batchWorkers, len(hashes)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				res, err := s.getAvatar(ctx, hashes[i], args)
+				avatars[i], errs[i] = res, itemError(err)
+			}
+		}()
+	}
+
+	for i := range hashes {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return avatars, errs, nil
+}
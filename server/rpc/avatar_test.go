@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	avatarv1 "github.com/AH-dark/gravatar-with-qq-avatar/api/avatar/v1"
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+)
+
+// fakeService serves the avatars and errors it holds, the methods a test doesn't need panic.
+type fakeService struct {
+	avatar.Service
+
+	avatars map[string]*avatar.Avatar
+	errs    map[string]error
+}
+
+func (s *fakeService) GetAvatar(_ context.Context, hash string, _ avatar.GetAvatarArgs) (*avatar.Avatar, error) {
+	return s.avatars[hash], s.errs[hash]
+}
+
+var testAvatar = &avatar.Avatar{
+	Data:        []byte("png"),
+	ContentType: "image/png",
+	ContentHash: "abc",
+	ETag:        `"abc"`,
+	Width:       80,
+	Height:      80,
+	Source:      avatar.SourceQQ,
+}
+
+// newTestClient serves the fake service over an in-memory connection like NewServer does.
+func newTestClient(t *testing.T) avatarv1.AvatarServiceClient {
+	conf := &config.Config{}
+	conf.GRPC.Batch.MaxHashes = 3
+	conf.GRPC.Batch.Workers = 2
+
+	svc := &fakeService{
+		avatars: map[string]*avatar.Avatar{"known": testAvatar},
+		errs: map[string]error{
+			"upscale": fmt.Errorf("%w: 80 > 40", avatar.ErrUpscaleRejected),
+			"broken":  errors.New("cassandra unavailable"),
+		},
+	}
+
+	svr, err := NewServer(context.Background(), prometheus.NewRegistry(), NewAvatarServer(AvatarServerParams{Config: conf, AvatarService: svc}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = svr.Serve(lis) }()
+	t.Cleanup(svr.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return avatarv1.NewAvatarServiceClient(conn)
+}
+
+func TestAvatarServer_GetAvatar(t *testing.T) {
+	asserts := assert.New(t)
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	res, err := client.GetAvatar(ctx, &avatarv1.GetAvatarRequest{Hash: "known"})
+	asserts.NoError(err)
+	asserts.Equal([]byte("png"), res.GetData())
+	asserts.True(res.GetMetadata().GetFound())
+	asserts.Equal(avatarv1.Source_SOURCE_QQ, res.GetMetadata().GetSource())
+	asserts.Equal(`"abc"`, res.GetMetadata().GetEtag())
+
+	for _, c := range []struct {
+		req  *avatarv1.GetAvatarRequest
+		code codes.Code
+	}{
+		{&avatarv1.GetAvatarRequest{}, codes.InvalidArgument},
+		{&avatarv1.GetAvatarRequest{Hash: "known", Options: &avatarv1.AvatarOptions{Size: -1}}, codes.InvalidArgument},
+		{&avatarv1.GetAvatarRequest{Hash: "known", Options: &avatarv1.AvatarOptions{Size: maxSize + 1}}, codes.InvalidArgument},
+		{&avatarv1.GetAvatarRequest{Hash: "unknown"}, codes.NotFound},
+		{&avatarv1.GetAvatarRequest{Hash: "upscale"}, codes.FailedPrecondition},
+		{&avatarv1.GetAvatarRequest{Hash: "broken"}, codes.Internal},
+	} {
+		_, err := client.GetAvatar(ctx, c.req)
+		asserts.Equal(c.code, status.Code(err), c.req.String())
+	}
+}
+
+func TestAvatarServer_ResolveAvatar(t *testing.T) {
+	asserts := assert.New(t)
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	res, err := client.ResolveAvatar(ctx, &avatarv1.ResolveAvatarRequest{Hash: "known"})
+	asserts.NoError(err)
+	asserts.Equal(int32(80), res.GetMetadata().GetWidth())
+
+	_, err = client.ResolveAvatar(ctx, &avatarv1.ResolveAvatarRequest{Hash: "unknown"})
+	asserts.Equal(codes.NotFound, status.Code(err))
+
+	_, err = client.ResolveAvatar(ctx, &avatarv1.ResolveAvatarRequest{Hash: "upscale"})
+	asserts.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func TestAvatarServer_BatchGetAvatars(t *testing.T) {
+	asserts := assert.New(t)
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	// a failing hash only fails its own entry
+	res, err := client.BatchGetAvatars(ctx, &avatarv1.BatchGetAvatarsRequest{Hashes: []string{"known", "unknown", "upscale"}})
+	asserts.NoError(err)
+	asserts.Len(res.GetAvatars(), 3)
+
+	asserts.True(res.GetAvatars()[0].GetMetadata().GetFound())
+	asserts.Equal([]byte("png"), res.GetAvatars()[0].GetData())
+	asserts.Nil(res.GetAvatars()[0].GetError())
+
+	asserts.Equal("unknown", res.GetAvatars()[1].GetMetadata().GetHash())
+	asserts.False(res.GetAvatars()[1].GetMetadata().GetFound())
+	asserts.Nil(res.GetAvatars()[1].GetError())
+
+	asserts.False(res.GetAvatars()[2].GetMetadata().GetFound())
+	asserts.Equal(int32(codes.FailedPrecondition), res.GetAvatars()[2].GetError().GetCode())
+
+	resolved, err := client.BatchResolveAvatars(ctx, &avatarv1.BatchResolveAvatarsRequest{Hashes: []string{"broken", "", "known"}})
+	asserts.NoError(err)
+	asserts.Equal(int32(codes.Internal), resolved.GetAvatars()[0].GetError().GetCode())
+	asserts.Equal(int32(codes.InvalidArgument), resolved.GetAvatars()[1].GetError().GetCode())
+	asserts.True(resolved.GetAvatars()[2].GetMetadata().GetFound())
+}
+
+func TestAvatarServer_BatchLimits(t *testing.T) {
+	asserts := assert.New(t)
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	// a malformed request fails the whole batch
+	for _, req := range []*avatarv1.BatchGetAvatarsRequest{
+		{},
+		{Hashes: []string{"known", "known", "known", "known"}},
+		{Hashes: []string{"known"}, Options: &avatarv1.AvatarOptions{Size: -1}},
+	} {
+		_, err := client.BatchGetAvatars(ctx, req)
+		asserts.Equal(codes.InvalidArgument, status.Code(err), req.String())
+	}
+
+	_, err := client.BatchResolveAvatars(ctx, &avatarv1.BatchResolveAvatarsRequest{Hashes: []string{"a", "b", "c", "d"}})
+	asserts.Equal(codes.InvalidArgument, status.Code(err))
+
+	res, err := client.BatchGetAvatars(ctx, &avatarv1.BatchGetAvatarsRequest{Hashes: []string{"known", "known", "known"}})
+	asserts.NoError(err)
+	asserts.Len(res.GetAvatars(), 3)
+}
@@ -0,0 +1,93 @@
+// Package rpc serves the avatar service over gRPC to internal consumers, next to the http server.
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	avatarv1 "github.com/AH-dark/gravatar-with-qq-avatar/api/avatar/v1"
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/server/rpc")
+
+func NewServer(ctx context.Context, promRegistry *promclient.Registry, avatarServer avatarv1.AvatarServiceServer) (*grpc.Server, error) {
+	ctx, span := tracer.Start(ctx, "server.rpc.NewServer")
+	defer span.End()
+
+	metrics := grpcprom.NewServerMetrics(grpcprom.WithServerHandlingTimeHistogram())
+	if err := promRegistry.Register(metrics); err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("register grpc metrics failed", zap.Error(err))
+		return nil, err
+	}
+
+	svr := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor()),
+	)
+	avatarv1.RegisterAvatarServiceServer(svr, avatarServer)
+	metrics.InitializeMetrics(svr)
+
+	return svr, nil
+}
+
+func RunServer(ctx context.Context, conf *config.Config, svr *grpc.Server, lc fx.Lifecycle) error {
+	ctx, span := tracer.Start(ctx, "server.rpc.RunServer")
+	defer span.End()
+
+	if !conf.GRPC.Enabled {
+		return nil
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			addr := fmt.Sprintf("%s:%d", conf.GRPC.Address, conf.GRPC.Port)
+			lis, err := net.Listen(conf.GRPC.Network, addr)
+			if err != nil {
+				otelzap.L().Ctx(ctx).Error("listen grpc failed", zap.String("address", addr), zap.Error(err))
+				return err
+			}
+
+			go func() {
+				if err := svr.Serve(lis); err != nil {
+					otelzap.L().Error("serve grpc failed", zap.Error(err))
+				}
+			}()
+
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, conf.Server.Shutdown.Timeout)
+			defer cancel()
+
+			stopped := make(chan struct{})
+			go func() {
+				svr.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				// cut the calls still running after server.shutdown.timeout
+				svr.Stop()
+			}
+
+			return nil
+		},
+	})
+
+	return nil
+}
@@ -3,34 +3,48 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
+
 	"go.uber.org/fx"
 
+	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/cloudwego/hertz/pkg/network/netpoll"
 	hertztracing "github.com/hertz-contrib/obs-opentelemetry/tracing"
 	promclient "github.com/prometheus/client_golang/prometheus"
-	"github.com/spf13/viper"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
 
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 	hertzloggerzap "github.com/AH-dark/gravatar-with-qq-avatar/pkg/hertzloggerzap"
 	hertzprometheus "github.com/AH-dark/gravatar-with-qq-avatar/pkg/hertzprometheus"
 )
 
 var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/server")
 
-func NewServer(ctx context.Context, vip *viper.Viper, promRegistry *promclient.Registry) *server.Hertz {
+// NewHertzLogger is the logger of hertz, its level is changed through the admin server.
+func NewHertzLogger(logger *otelzap.Logger) *hertzloggerzap.Logger {
+	return hertzloggerzap.NewLoggerWithZapLogger(logger.Named("hertz"))
+}
+
+func NewServer(
+	ctx context.Context,
+	conf *config.Config,
+	promRegistry *promclient.Registry,
+	logger *hertzloggerzap.Logger,
+) (*server.Hertz, error) {
 	ctx, span := tracer.Start(ctx, "server.NewServer")
 	defer span.End()
 
-	hlog.SetLogger(hertzloggerzap.NewLoggerWithZapLogger(otelzap.L().Named("hertz")))
+	hlog.SetLogger(logger)
 
 	traceOption, cfg := hertztracing.NewServerTracer()
 	svr := server.Default(
 		traceOption,
-		server.WithNetwork(vip.GetString("server.network")),
-		server.WithHostPorts(fmt.Sprintf("%s:%d", vip.GetString("server.host"), vip.GetUint16("server.port"))),
+		server.WithNetwork(conf.Server.Network),
+		server.WithHostPorts(fmt.Sprintf("%s:%d", conf.Server.Address, conf.Server.Port)),
 		server.WithHandleMethodNotAllowed(true), server.WithTracer(hertzprometheus.NewServerTracer(
 			"",
 			"",
@@ -43,16 +57,68 @@ func NewServer(ctx context.Context, vip *viper.Viper, promRegistry *promclient.R
 	)
 	svr.Use(hertztracing.ServerMiddleware(cfg))
 
-	return svr
+	clientIPOptions, err := newClientIPOptions(conf.Server)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("invalid trusted proxies", zap.Error(err))
+		return nil, err
+	}
+	svr.SetClientIPFunc(app.ClientIPWithOption(clientIPOptions))
+
+	return svr, nil
+}
+
+// newClientIPOptions only trusts the remote ip headers when the request comes from one of the configured proxies.
+func newClientIPOptions(conf config.ServerConfig) (app.ClientIPOptions, error) {
+	opts := app.ClientIPOptions{
+		RemoteIPHeaders: conf.RemoteIPHeaders,
+	}
+
+	for _, proxy := range conf.TrustedProxies {
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return app.ClientIPOptions{}, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+
+			cidr = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+		}
+
+		opts.TrustedCIDRs = append(opts.TrustedCIDRs, cidr)
+	}
+
+	return opts, nil
 }
 
-func RunServer(ctx context.Context, svr *server.Hertz, lc fx.Lifecycle) error {
+func RunServer(ctx context.Context, conf *config.Config, svr *server.Hertz, lc fx.Lifecycle, shutdowner fx.Shutdowner) error {
 	ctx, span := tracer.Start(ctx, "server.RunServer")
 	defer span.End()
 
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
-			go svr.Spin()
+			// fx handles the signals, unlike Spin, Run leaves the shutdown to the stop hook
+			go func() {
+				if err := svr.Run(); err != nil {
+					otelzap.L().Error("run server failed", zap.Error(err))
+					_ = shutdowner.Shutdown(fx.ExitCode(1))
+				}
+			}()
+
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, conf.Server.Shutdown.Timeout)
+			defer cancel()
+
+			// in-flight requests are finished, idle connections closed
+			if err := svr.Shutdown(ctx); err != nil {
+				otelzap.L().Ctx(ctx).Error("shutdown server failed", zap.Error(err))
+				return err
+			}
 
 			return nil
 		},
@@ -1,12 +1,15 @@
 package services
 
 import (
-	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
 	"go.uber.org/fx"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/avatar"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/cdn"
 )
 
 func Module() fx.Option {
 	return fx.Options(
+		fx.Provide(cdn.NewPurger),
 		fx.Provide(avatar.NewService),
 	)
 }
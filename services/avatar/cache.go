@@ -0,0 +1,220 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+)
+
+const (
+	cacheKindAvatar      = "avatar"
+	cacheKindPlaceholder = "placeholder"
+	cacheKindMontage     = "montage"
+	cacheKindProfile     = "profile"
+)
+
+// errDegraded keeps a stale entry when the refreshed one was resolved while qq failed.
+var errDegraded = errors.New("qq avatar unavailable, not caching the fallback")
+
+// cacheEntry is a cached GetAvatar, GetPlaceholder, GetMontage or GetProfile result, an entry without either caches a miss.
+type cacheEntry struct {
+	Avatar      *Avatar
+	Placeholder *Placeholder
+	Profile     *Profile
+	// UpscaleRejected is the message of an ErrUpscaleRejected, it is cached like a miss.
+	UpscaleRejected string
+	// degraded entries are served but never stored, see resolvedImage.
+	degraded bool
+
+	StoredAt   time.Time
+	FreshUntil time.Time
+	// StaleUntil ends the stale-while-revalidate window, the entry is still served while it is refreshed in the background.
+	StaleUntil time.Time
+	// ErrorUntil ends the stale-if-error window, the entry is still served when the upstreams fail.
+	ErrorUntil time.Time
+}
+
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return now.Before(e.FreshUntil)
+}
+
+func (e *cacheEntry) revalidatable(now time.Time) bool {
+	return now.Before(e.StaleUntil)
+}
+
+func (e *cacheEntry) usableOnError(now time.Time) bool {
+	return now.Before(e.ErrorUntil)
+}
+
+type avatarCache interface {
+	get(ctx context.Context, key string) (*cacheEntry, error)
+	// set stores the entry of the hash, invalidating any of the members drops it as well.
+	set(ctx context.Context, hash string, key string, entry *cacheEntry, members ...string) error
+	// invalidate drops the entries of every size and format of the hashes, and those they are a member of.
+	invalidate(ctx context.Context, hashes ...string) error
+}
+
+type cachePolicy struct {
+	enabled              bool
+	ttl                  time.Duration
+	notFoundTTL          time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+}
+
+func newCachePolicy(conf config.CacheConfig) cachePolicy {
+	return cachePolicy{
+		enabled:              conf.Enabled,
+		ttl:                  conf.TTL,
+		notFoundTTL:          conf.NotFoundTTL,
+		staleWhileRevalidate: conf.StaleWhileRevalidate,
+		staleIfError:         conf.StaleIfError,
+	}
+}
+
+func (e *cacheEntry) found() bool {
+	return e.Avatar != nil || e.Placeholder != nil || e.Profile != nil
+}
+
+// newEntry sets the timestamps of a fetched entry.
+func (p cachePolicy) newEntry(entry *cacheEntry, now time.Time) *cacheEntry {
+	ttl := p.ttl
+	if !entry.found() {
+		ttl = p.notFoundTTL
+	}
+
+	entry.StoredAt = now
+	entry.FreshUntil = now.Add(ttl)
+	entry.StaleUntil = now.Add(ttl + p.staleWhileRevalidate)
+	entry.ErrorUntil = now.Add(ttl + p.staleIfError)
+
+	return entry
+}
+
+// cacheKey identifies a GetAvatar or GetPlaceholder call, the arguments are encoded like a sorted query string.
+// The hash is a redis cluster hash tag, so the entries of a hash and their index share a slot.
+func cacheKey(kind string, hash string, args GetAvatarArgs) string {
+	query := url.Values{
+		"s":    []string{strconv.FormatInt(args.Size, 10)},
+		"d":    []string{args.Default},
+		"f":    []string{strconv.FormatBool(args.ForceDefault)},
+		"r":    []string{args.Rating},
+		"webp": []string{strconv.FormatBool(args.EnableWebp)},
+	}
+
+	if shape := args.Shape; !shape.IsZero() {
+		query.Set("circle", strconv.FormatBool(shape.Circle))
+		query.Set("radius", strconv.Itoa(shape.Radius))
+		query.Set("padding", strconv.Itoa(shape.Padding))
+		query.Set("border", strconv.Itoa(shape.BorderWidth))
+		query.Set("border_color", imaging.FormatColor(shape.BorderColor))
+		if shape.Background != nil {
+			query.Set("bg", imaging.FormatColor(*shape.Background))
+		}
+	}
+
+	path := ""
+	if kind != cacheKindAvatar {
+		path = "/" + kind
+	}
+
+	return "avatar:v3:{" + hash + "}" + path + "?" + query.Encode()
+}
+
+// cacheIndexKey is the set of cache keys stored for a hash.
+func cacheIndexKey(hash string) string {
+	return "avatar:v3:{" + hash + "}:keys"
+}
+
+type redisAvatarCache struct {
+	rdb redis.UniversalClient
+}
+
+func (c *redisAvatarCache) get(ctx context.Context, key string) (*cacheEntry, error) {
+	b, err := c.rdb.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&entry); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (c *redisAvatarCache) set(ctx context.Context, hash string, key string, entry *cacheEntry, members ...string) error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(entry); err != nil {
+		return err
+	}
+
+	expiration := entry.StaleUntil
+	if entry.ErrorUntil.After(expiration) {
+		expiration = entry.ErrorUntil
+	}
+
+	ttl := time.Until(expiration)
+	index := func(pipe redis.Pipeliner, hash string) {
+		pipe.SAdd(ctx, cacheIndexKey(hash), key)
+		// the index must outlive every key in it: NX sets the ttl of a new index,
+		// GT only ever extends it so a shorter lived entry doesn't cut it (needs redis 7)
+		pipe.ExpireNX(ctx, cacheIndexKey(hash), ttl)
+		pipe.ExpireGT(ctx, cacheIndexKey(hash), ttl)
+	}
+
+	// the indexes of the members are in other slots, so they can't join the transaction,
+	// they are written first so the entry is never stored without them
+	if len(members) > 0 {
+		if _, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, member := range members {
+				index(pipe, member)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, b.Bytes(), ttl)
+		index(pipe, hash)
+		return nil
+	})
+
+	return err
+}
+
+func (c *redisAvatarCache) invalidate(ctx context.Context, hashes ...string) error {
+	indexes := make([]*redis.StringSliceCmd, len(hashes))
+	if _, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, hash := range hashes {
+			indexes[i] = pipe.SMembers(ctx, cacheIndexKey(hash))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// each hash has a slot of its own in a cluster, so the keys are deleted per hash
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, hash := range hashes {
+			pipe.Del(ctx, append(indexes[i].Val(), cacheIndexKey(hash))...)
+		}
+		return nil
+	})
+
+	return err
+}
@@ -0,0 +1,375 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nfnt/resize"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+)
+
+type memoryAvatarCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	// members are the hashes, besides its own, whose invalidation drops a key
+	members map[string][]string
+}
+
+func (c *memoryAvatarCache) get(_ context.Context, key string) (*cacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries[key], nil
+}
+
+func (c *memoryAvatarCache) set(_ context.Context, _ string, key string, entry *cacheEntry, members ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = entry
+	if len(members) > 0 {
+		if c.members == nil {
+			c.members = make(map[string][]string)
+		}
+		c.members[key] = members
+	}
+	return nil
+}
+
+func (c *memoryAvatarCache) invalidate(_ context.Context, hashes ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		for _, hash := range hashes {
+			if strings.HasPrefix(key, "avatar:v3:{"+hash+"}") || slices.Contains(c.members[key], hash) {
+				delete(c.entries, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestService_GetAvatarStaleWhileRevalidate(t *testing.T) {
+	asserts := assert.New(t)
+
+	var hits atomic.Int32
+	var failing atomic.Bool
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	}))
+	defer svr.Close()
+
+	cache := &memoryAvatarCache{entries: make(map[string]*cacheEntry)}
+	s := &service{
+		MD5QQMappingRepo: fakeMappingRepo{},
+		resizer:          &resizer{filter: resize.Bilinear, upscale: UpscaleAllow},
+		cache:            cache,
+		cachePolicy: cachePolicy{
+			enabled:              true,
+			ttl:                  time.Hour,
+			staleWhileRevalidate: time.Hour,
+			staleIfError:         2 * time.Hour,
+		},
+	}
+	_, s.gravatarClient = newTestUpstream(t, config.UpstreamConfig{}, "gravatar", svr.URL+"/")
+
+	var err error
+	s.refreshQueue, err = newRefreshQueue(prometheus.NewRegistry(), 1, 1, time.Second, s.refresh)
+	asserts.NoError(err)
+	s.refreshQueue.start()
+	defer func() { _ = s.refreshQueue.shutdown(context.Background()) }()
+
+	args := GetAvatarArgs{Size: 80}
+	key := cacheKey(cacheKindAvatar, "hash", args)
+
+	res, err := s.GetAvatar(context.Background(), "hash", args)
+	asserts.NoError(err)
+	asserts.Equal(SourceGravatar, res.Source)
+	asserts.EqualValues(1, hits.Load())
+
+	// fresh entries are served from the cache
+	_, err = s.GetAvatar(context.Background(), "hash", args)
+	asserts.NoError(err)
+	asserts.EqualValues(1, hits.Load())
+
+	// stale entries are served right away and refreshed in the background
+	entry, _ := cache.get(context.Background(), key)
+	stale := *entry
+	stale.FreshUntil = time.Now().Add(-time.Second)
+	_ = cache.set(context.Background(), "hash", key, &stale)
+
+	res, err = s.GetAvatar(context.Background(), "hash", args)
+	asserts.NoError(err)
+	asserts.NotNil(res)
+	asserts.Eventually(func() bool {
+		entry, _ := cache.get(context.Background(), key)
+		return entry.fresh(time.Now())
+	}, time.Second, 10*time.Millisecond)
+	asserts.EqualValues(2, hits.Load())
+
+	// expired entries are still served when the upstream fails
+	failing.Store(true)
+	entry, _ = cache.get(context.Background(), key)
+	expired := *entry
+	expired.FreshUntil = time.Now().Add(-time.Second)
+	expired.StaleUntil = time.Now().Add(-time.Second)
+	_ = cache.set(context.Background(), "hash", key, &expired)
+
+	res, err = s.GetAvatar(context.Background(), "hash", args)
+	asserts.NoError(err)
+	asserts.NotNil(res)
+}
+
+// failingMappingRepo fails like cassandra during an outage.
+type failingMappingRepo struct{}
+
+func (failingMappingRepo) GetQQIdByEmailMD5(context.Context, string) (int64, error) {
+	return 0, errors.New("cassandra unavailable")
+}
+
+func (failingMappingRepo) InsertMapping(context.Context, int64, string) error {
+	return errors.New("cassandra unavailable")
+}
+
+func TestService_GetAvatarQQUnavailable(t *testing.T) {
+	asserts := assert.New(t)
+
+	var found atomic.Bool
+	found.Store(true)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !found.Load() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	}))
+	defer svr.Close()
+
+	cache := &memoryAvatarCache{entries: make(map[string]*cacheEntry)}
+	s := &service{
+		MD5QQMappingRepo: failingMappingRepo{},
+		resizer:          &resizer{filter: resize.Bilinear, upscale: UpscaleAllow},
+		cache:            cache,
+		cachePolicy: cachePolicy{
+			enabled:      true,
+			ttl:          time.Hour,
+			notFoundTTL:  time.Hour,
+			staleIfError: 2 * time.Hour,
+		},
+	}
+	_, s.gravatarClient = newTestUpstream(t, config.UpstreamConfig{}, "gravatar", svr.URL+"/")
+
+	args := GetAvatarArgs{Size: 80}
+	key := cacheKey(cacheKindAvatar, "hash", args)
+
+	// the gravatar fallback is served but not cached
+	res, err := s.GetAvatar(context.Background(), "hash", args)
+	asserts.NoError(err)
+	asserts.Equal(SourceGravatar, res.Source)
+	asserts.Empty(cache.entries)
+
+	// neither is a miss, which might just be qq being down
+	found.Store(false)
+	_, err = s.GetAvatar(context.Background(), "hash", args)
+	asserts.ErrorContains(err, "cassandra unavailable")
+	asserts.Empty(cache.entries)
+
+	// an expired qq entry is preferred over the fallback, and a refresh keeps it
+	found.Store(true)
+	stale := &cacheEntry{
+		Avatar:     &Avatar{Source: SourceQQ},
+		FreshUntil: time.Now().Add(-time.Second),
+		StaleUntil: time.Now().Add(-time.Second),
+		ErrorUntil: time.Now().Add(time.Hour),
+	}
+	_ = cache.set(context.Background(), "hash", key, stale)
+
+	res, err = s.GetAvatar(context.Background(), "hash", args)
+	asserts.NoError(err)
+	asserts.Equal(SourceQQ, res.Source)
+
+	asserts.ErrorIs(s.refresh(context.Background(), newRefreshTask(cacheKindAvatar, "hash", args)), errDegraded)
+	entry, _ := cache.get(context.Background(), key)
+	asserts.Same(stale, entry)
+}
+
+func TestService_GetAvatarUpscaleRejectedCached(t *testing.T) {
+	asserts := assert.New(t)
+
+	var hits atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 40, 40)))
+	}))
+	defer svr.Close()
+
+	cache := &memoryAvatarCache{entries: make(map[string]*cacheEntry)}
+	s := &service{
+		MD5QQMappingRepo: fakeMappingRepo{},
+		resizer:          &resizer{filter: resize.Bilinear, upscale: UpscaleReject},
+		cache:            cache,
+		cachePolicy:      cachePolicy{enabled: true, ttl: time.Hour, notFoundTTL: time.Minute},
+	}
+	_, s.gravatarClient = newTestUpstream(t, config.UpstreamConfig{}, "gravatar", svr.URL+"/")
+
+	args := GetAvatarArgs{Size: 80}
+	for i := 0; i < 2; i++ {
+		_, err := s.GetAvatar(context.Background(), "hash", args)
+		asserts.ErrorIs(err, ErrUpscaleRejected)
+		asserts.ErrorContains(err, "80 exceeds 40")
+	}
+
+	// the rejection is cached like a miss
+	asserts.EqualValues(1, hits.Load())
+	entry, _ := cache.get(context.Background(), cacheKey(cacheKindAvatar, "hash", args))
+	asserts.WithinDuration(time.Now().Add(time.Minute), entry.FreshUntil, time.Second)
+
+	_, ok := s.CachedAvatar(context.Background(), "hash", args)
+	asserts.False(ok)
+}
+
+func TestRefreshQueue_Enqueue(t *testing.T) {
+	asserts := assert.New(t)
+
+	release := make(chan struct{})
+	var runs atomic.Int32
+	q, err := newRefreshQueue(prometheus.NewRegistry(), 1, 1, time.Second, func(ctx context.Context, task refreshTask) error {
+		runs.Add(1)
+		<-release
+		return nil
+	})
+	asserts.NoError(err)
+
+	asserts.True(q.enqueue(refreshTask{key: "a"}))
+	// a pending key is only queued once
+	asserts.True(q.enqueue(refreshTask{key: "a"}))
+	// the queue is full
+	asserts.False(q.enqueue(refreshTask{key: "b"}))
+
+	q.start()
+	asserts.Eventually(func() bool { return runs.Load() == 1 }, time.Second, 10*time.Millisecond)
+	close(release)
+	asserts.NoError(q.shutdown(context.Background()))
+	asserts.EqualValues(1, runs.Load())
+}
+
+type recordingPurger struct {
+	keys []string
+}
+
+func (p *recordingPurger) Purge(_ context.Context, keys ...string) error {
+	p.keys = append(p.keys, keys...)
+	return nil
+}
+
+func TestService_Invalidate(t *testing.T) {
+	asserts := assert.New(t)
+
+	cache := &memoryAvatarCache{entries: map[string]*cacheEntry{
+		cacheKey(cacheKindAvatar, "hash", GetAvatarArgs{Size: 80}):      {},
+		cacheKey(cacheKindPlaceholder, "hash", GetAvatarArgs{Size: 80}): {},
+		cacheKey(cacheKindAvatar, "other", GetAvatarArgs{Size: 80}):     {},
+	}}
+	purger := &recordingPurger{}
+	s := &service{cache: cache, Purger: purger}
+
+	asserts.NoError(s.Invalidate(context.Background(), "hash"))
+	asserts.Len(cache.entries, 1)
+	asserts.Equal([]string{"avatar-hash"}, purger.keys)
+}
+
+func TestRedisAvatarCache_Invalidate(t *testing.T) {
+	asserts := assert.New(t)
+
+	mr := miniredis.RunT(t)
+	c := &redisAvatarCache{rdb: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+	ctx := context.Background()
+
+	entry := cachePolicy{ttl: time.Hour}.newEntry(&cacheEntry{Avatar: &Avatar{Source: SourceQQ}}, time.Now())
+	for _, hash := range []string{"a", "b", "other"} {
+		asserts.NoError(c.set(ctx, hash, cacheKey(cacheKindAvatar, hash, GetAvatarArgs{Size: 80}), entry))
+	}
+	asserts.NoError(c.set(ctx, "a", cacheKey(cacheKindPlaceholder, "a", GetAvatarArgs{Size: 80}), entry))
+	// montages are dropped with any of their members
+	montages := []refreshTask{
+		newMontageTask([]string{"other", "b"}, MontageArgs{Layout: imaging.LayoutGrid}),
+		newMontageTask([]string{"other"}, MontageArgs{Layout: imaging.LayoutGrid}),
+	}
+	for _, task := range montages {
+		asserts.NoError(c.set(ctx, task.hash, task.key, entry, task.members...))
+	}
+
+	asserts.NoError(c.invalidate(ctx, "a", "b"))
+	for _, key := range []string{
+		cacheKey(cacheKindAvatar, "a", GetAvatarArgs{Size: 80}),
+		cacheKey(cacheKindPlaceholder, "a", GetAvatarArgs{Size: 80}),
+		cacheKey(cacheKindAvatar, "b", GetAvatarArgs{Size: 80}),
+		montages[0].key,
+	} {
+		res, err := c.get(ctx, key)
+		asserts.NoError(err)
+		asserts.Nil(res)
+	}
+
+	for _, key := range []string{cacheKey(cacheKindAvatar, "other", GetAvatarArgs{Size: 80}), montages[1].key} {
+		res, err := c.get(ctx, key)
+		asserts.NoError(err)
+		asserts.NotNil(res)
+	}
+
+	// the index of a member expires with the montage
+	asserts.Greater(mr.TTL(cacheIndexKey("other")), time.Duration(0))
+}
+
+func TestService_InsertMappings(t *testing.T) {
+	asserts := assert.New(t)
+
+	cache := &memoryAvatarCache{entries: map[string]*cacheEntry{
+		cacheKey(cacheKindAvatar, "a", GetAvatarArgs{Size: 80}):     {},
+		cacheKey(cacheKindAvatar, "b", GetAvatarArgs{Size: 80}):     {},
+		cacheKey(cacheKindAvatar, "other", GetAvatarArgs{Size: 80}): {},
+	}}
+	purger := &recordingPurger{}
+	repo := fakeMappingRepo{}
+	s := &service{cache: cache, Purger: purger, MD5QQMappingRepo: repo}
+
+	// a mapping write purges the hashes it changed, in one batch
+	asserts.NoError(s.InsertMappings(context.Background(), Mapping{Hash: "a", QQ: 1}, Mapping{Hash: "b", QQ: 2}))
+	asserts.Equal(fakeMappingRepo{"a": 1, "b": 2}, repo)
+	asserts.Len(cache.entries, 1)
+	asserts.Equal([]string{"avatar-a", "avatar-b"}, purger.keys)
+
+	// nothing is purged for a mapping that wasn't written
+	purger.keys = nil
+	s.MD5QQMappingRepo = failingMappingRepo{}
+	asserts.Error(s.InsertMappings(context.Background(), Mapping{Hash: "c", QQ: 3}))
+	asserts.Empty(purger.keys)
+}
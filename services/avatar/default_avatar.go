@@ -0,0 +1,91 @@
+package avatar
+
+import (
+	"context"
+	"image"
+	"strings"
+
+	"github.com/AH-dark/bytestring"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/cryptor"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imagehash"
+)
+
+// defaultAvatarDetector recognizes the placeholder images qlogo serves with 200 for accounts without an avatar.
+type defaultAvatarDetector struct {
+	contentHashes    map[string]struct{}
+	perceptualHashes []imagehash.Hash
+	maxDistance      int
+}
+
+func newDefaultAvatarDetector(ctx context.Context, conf config.DefaultAvatarsConfig) (*defaultAvatarDetector, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.newDefaultAvatarDetector")
+	defer span.End()
+
+	d := &defaultAvatarDetector{
+		contentHashes: make(map[string]struct{}),
+		maxDistance:   conf.MaxDistance,
+	}
+
+	for _, hash := range conf.ContentHashes {
+		d.contentHashes[strings.ToLower(hash)] = struct{}{}
+	}
+
+	for _, raw := range conf.PerceptualHashes {
+		hash, err := imagehash.ParseHash(raw)
+		if err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("parse default avatar perceptual hash failed", zap.String("hash", raw), zap.Error(err))
+			return nil, err
+		}
+
+		d.perceptualHashes = append(d.perceptualHashes, hash)
+	}
+
+	// no hashes of the placeholders are shipped, they change with qlogo and must come from the operator
+	if len(d.contentHashes) == 0 && len(d.perceptualHashes) == 0 {
+		otelzap.L().Ctx(ctx).Warn("no default avatar hashes configured, qq placeholder images are served as avatars")
+	}
+
+	return d, nil
+}
+
+// ContentHash is the hex sha256 of the content, the ETags of the served bodies are built from it.
+func ContentHash(content []byte) string {
+	return bytestring.BytesToString(cryptor.Sha256(content))
+}
+
+// isDefaultContent only compares the raw bytes against the known content hashes, without decoding the image.
+func (d *defaultAvatarDetector) isDefaultContent(ctx context.Context, content []byte) bool {
+	sum := ContentHash(content)
+	otelzap.L().Ctx(ctx).Debug("qq avatar probe hash", zap.String("content_hash", sum))
+
+	_, ok := d.contentHashes[sum]
+	return ok
+}
+
+// isDefault reports whether the downloaded avatar is one of the known default images,
+// either byte for byte or close enough to one of the perceptual hashes.
+func (d *defaultAvatarDetector) isDefault(ctx context.Context, content []byte, img image.Image) bool {
+	sum := ContentHash(content)
+	if _, ok := d.contentHashes[sum]; ok {
+		return true
+	}
+
+	phash := imagehash.DHash(img)
+	otelzap.L().Ctx(ctx).Debug("qq avatar hashes",
+		zap.String("content_hash", sum),
+		zap.Stringer("perceptual_hash", phash),
+	)
+
+	for _, hash := range d.perceptualHashes {
+		if phash.Distance(hash) <= d.maxDistance {
+			return true
+		}
+	}
+
+	return false
+}
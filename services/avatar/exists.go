@@ -0,0 +1,91 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gocql/gocql"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+)
+
+func (s *service) Exists(ctx context.Context, hash string, args GetAvatarArgs) (Source, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.Exists")
+	defer span.End()
+
+	ok, err := s.qqAvatarExists(ctx, hash)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Warn("probe qq avatar failed, falling back to gravatar", zap.Error(err))
+	} else if ok {
+		return SourceQQ, nil
+	}
+
+	ok, err = s.gravatarExists(ctx, hash, args)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Warn("probe gravatar failed", zap.Error(err))
+		return "", err
+	} else if ok {
+		return SourceGravatar, nil
+	}
+
+	return "", nil
+}
+
+// qqAvatarExists downloads the smallest qlogo spec and only compares its content hash
+// with the known default avatars, the image is never decoded.
+func (s *service) qqAvatarExists(ctx context.Context, hash string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.qqAvatarExists")
+	defer span.End()
+
+	qqid, err := s.MD5QQMappingRepo.GetQQIdByEmailMD5(ctx, hash)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("get qq id by email md5 failed", zap.Error(err))
+		return false, err
+	}
+
+	resp, err := s.qqAvatarClient.R().
+		SetContext(ctx).
+		SetQueryParam("dst_uin", strconv.FormatInt(qqid, 10)).
+		SetQueryParam("spec", s.qqProbeSpec).
+		Get("headimg_dl")
+	if err != nil {
+		otelzap.L().Ctx(ctx).Error("probe qq avatar failed", zap.Error(err))
+		return false, err
+	} else if resp.IsErrorState() {
+		otelzap.L().Ctx(ctx).Error("probe qq avatar failed", zap.Error(resp.Err))
+		return false, fmt.Errorf("probe qq avatar failed: %v", resp.ErrorResult())
+	}
+
+	return !s.defaultAvatars.isDefaultContent(ctx, resp.Bytes()), nil
+}
+
+// gravatarExists sends a HEAD request with `d=404`, gravatar answers 404 when the hash has no avatar.
+func (s *service) gravatarExists(ctx context.Context, hash string, args GetAvatarArgs) (bool, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.gravatarExists")
+	defer span.End()
+
+	resp, err := s.gravatarClient.R().
+		SetContext(ctx).
+		SetPathParam("hash", hash).
+		SetQueryParams(map[string]string{
+			"d": "404",
+			"r": args.Rating,
+		}).
+		Head("{hash}")
+	if resp.GetStatusCode() == http.StatusNotFound {
+		return false, nil
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("probe gravatar failed", zap.Error(err))
+		return false, err
+	} else if resp.IsErrorState() {
+		otelzap.L().Ctx(ctx).Error("probe gravatar failed", zap.Error(resp.Err))
+		return false, fmt.Errorf("probe gravatar failed: %v", resp.ErrorResult())
+	}
+
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package avatar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+type fakeMappingRepo map[string]int64
+
+func (r fakeMappingRepo) GetQQIdByEmailMD5(_ context.Context, emailMD5 string) (int64, error) {
+	qqid, ok := r[emailMD5]
+	if !ok {
+		return 0, gocql.ErrNotFound
+	}
+
+	return qqid, nil
+}
+
+func (r fakeMappingRepo) InsertMapping(_ context.Context, qqid int64, emailMD5 string) error {
+	r[emailMD5] = qqid
+	return nil
+}
+
+func TestService_Exists(t *testing.T) {
+	asserts := assert.New(t)
+
+	qq := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asserts.Equal("40", r.URL.Query().Get("spec"))
+		if r.URL.Query().Get("dst_uin") == "1" {
+			_, _ = w.Write([]byte("default"))
+			return
+		}
+		_, _ = w.Write([]byte("avatar"))
+	}))
+	defer qq.Close()
+
+	gravatar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asserts.Equal(http.MethodHead, r.Method)
+		asserts.Equal("404", r.URL.Query().Get("d"))
+		if r.URL.Path != "/known" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer gravatar.Close()
+
+	s := &service{
+		MD5QQMappingRepo: fakeMappingRepo{"qq": 2, "qq-default": 1},
+		qqProbeSpec:      "40",
+	}
+	_, s.qqAvatarClient = newTestUpstream(t, config.UpstreamConfig{}, "qq", qq.URL+"/")
+	_, s.gravatarClient = newTestUpstream(t, config.UpstreamConfig{}, "gravatar", gravatar.URL+"/")
+
+	var err error
+	s.defaultAvatars, err = newDefaultAvatarDetector(context.Background(), config.DefaultAvatarsConfig{
+		ContentHashes: []string{ContentHash([]byte("default"))},
+	})
+	asserts.NoError(err)
+
+	for hash, expected := range map[string]Source{
+		"qq":         SourceQQ,
+		"qq-default": "",
+		"known":      SourceGravatar,
+		"unknown":    "",
+	} {
+		source, err := s.Exists(context.Background(), hash, GetAvatarArgs{})
+		asserts.NoError(err)
+		asserts.Equal(expected, source, hash)
+	}
+}
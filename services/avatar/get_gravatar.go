@@ -3,12 +3,13 @@ package avatar
 import (
 	"context"
 	"fmt"
-	"github.com/imroc/req/v3"
-	"github.com/samber/lo"
 	"image"
+	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/imroc/req/v3"
+	"github.com/samber/lo"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.uber.org/zap"
 )
@@ -16,49 +17,49 @@ import (
 type GetGravatarResult struct {
 	Avatar       image.Image
 	LastModified time.Time
+	Source       Source
 }
 
-func initGravatarClient() *req.Client {
-	c := req.C().
-		EnableDumpEachRequest().
-		SetBaseURL("https://gravatar.com/avatar/").
-		SetCommonHeader("Accept", "image/jpeg").
-		SetCommonQueryParams(map[string]string{
-			"s": "80",
-		}).
-		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
-			if resp.Err != nil { // There is an underlying error, e.g. network error or unmarshal error (SetSuccessResult or SetErrorResult was invoked before).
-				if dump := resp.Dump(); dump != "" { // Append dump content to original underlying error to help troubleshoot.
-					resp.Err = fmt.Errorf("error: %s\nraw content:\n%s", resp.Err.Error(), resp.Dump())
-				}
-				return nil // Skip the following logic if there is an underlying error.
-			}
-
-			if !resp.IsSuccessState() {
-				resp.Err = fmt.Errorf("bad response, raw content:\n%s", resp.Dump())
-			}
-
-			return nil
-		}).
-		WrapRoundTripFunc(WithTracer)
-
-	return c
-}
+// gravatarDefaultModified is the Last-Modified gravatar sends for its built-in default images.
+var gravatarDefaultModified = time.Date(1984, time.January, 11, 8, 0, 0, 0, time.UTC)
 
+// getGravatar fetches the avatar once with the default of the caller, `d=404` when there is none,
+// and tells a default image apart by where and how gravatar served it.
 func (s *service) getGravatar(ctx context.Context, hash string, args GetAvatarArgs) (GetGravatarResult, error) {
 	ctx, span := tracer.Start(ctx, "service.AvatarService.getGravatar")
 	defer span.End()
 
-	resp, err := s.gravatarClient.R().
+	defaultImage := lo.If(args.Default == "", "404").Else(args.Default)
+	if args.ForceDefault {
+		res, err := s.downloadGravatar(ctx, "", defaultImage, args)
+		if !lo.IsEmpty(res) {
+			res.Source = SourceDefault
+		}
+
+		return res, err
+	}
+
+	return s.downloadGravatar(ctx, hash, defaultImage, args)
+}
+
+func (s *service) downloadGravatar(ctx context.Context, hash string, defaultImage string, args GetAvatarArgs) (GetGravatarResult, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.downloadGravatar")
+	defer span.End()
+
+	r := s.gravatarClient.R().
 		SetContext(ctx).
-		SetPathParam("hash", lo.If(args.ForceDefault, "").Else(hash)).
+		SetPathParam("hash", hash).
 		SetQueryParams(map[string]string{
-			"d": args.Default,
+			"d": defaultImage,
 			"s": strconv.FormatInt(args.Size, 10),
 			"r": args.Rating,
-		}).
-		Get("{hash}")
-	if resp.GetStatusCode() == 404 {
+		})
+	if hash == "" {
+		r.SetQueryParam("f", "y")
+	}
+
+	resp, err := r.Get("{hash}")
+	if resp.GetStatusCode() == http.StatusNotFound {
 		return GetGravatarResult{}, nil
 	} else if err != nil {
 		otelzap.L().Ctx(ctx).Error("download gravatar failed", zap.Error(err))
@@ -76,14 +77,40 @@ func (s *service) getGravatar(ctx context.Context, hash string, args GetAvatarAr
 		return GetGravatarResult{}, err
 	}
 
-	lastModified, err := time.Parse(time.RFC1123, resp.Header.Get("Last-Modified"))
+	res := GetGravatarResult{
+		Avatar:       img,
+		LastModified: lastModified(ctx, resp),
+		Source:       SourceGravatar,
+	}
+	if isGravatarDefault(resp, res.LastModified) {
+		res.Source = SourceDefault
+	}
+
+	return res, nil
+}
+
+// isGravatarDefault reports whether gravatar answered with a default image: a default given as
+// url is a redirect to another host, the built-in ones carry a fixed Last-Modified.
+func isGravatarDefault(resp *req.Response, lastModified time.Time) bool {
+	if resp.Response.Request != nil && resp.Request.RawRequest != nil &&
+		resp.Response.Request.URL.Host != resp.Request.RawRequest.URL.Host {
+		return true
+	}
+
+	return lastModified.Equal(gravatarDefaultModified)
+}
+
+func lastModified(ctx context.Context, resp *req.Response) time.Time {
+	header := resp.GetHeader("Last-Modified")
+	if header == "" {
+		return time.Time{}
+	}
+
+	t, err := http.ParseTime(header)
 	if err != nil {
-		otelzap.L().Ctx(ctx).Warn("parse last modified failed", zap.Error(err))
-		lastModified = time.Time{}
+		otelzap.L().Ctx(ctx).Warn("parse last modified failed", zap.String("last_modified", header), zap.Error(err))
+		return time.Time{}
 	}
 
-	return GetGravatarResult{
-		Avatar:       img,
-		LastModified: lastModified,
-	}, nil
+	return t
 }
@@ -0,0 +1,73 @@
+package avatar
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+func TestService_GetGravatar(t *testing.T) {
+	asserts := assert.New(t)
+
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	}
+	fallback := httptest.NewServer(http.HandlerFunc(serve))
+	defer fallback.Close()
+
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch d := r.URL.Query().Get("d"); {
+		case r.URL.Path == "/known":
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		case d == "404":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case strings.HasPrefix(d, "http"):
+			http.Redirect(w, r, d, http.StatusFound)
+			return
+		default:
+			w.Header().Set("Last-Modified", "Wed, 11 Jan 1984 08:00:00 GMT")
+		}
+
+		serve(w, r)
+	}))
+	defer svr.Close()
+
+	_, c := newTestUpstream(t, config.UpstreamConfig{}, "gravatar", svr.URL+"/")
+	s := &service{gravatarClient: c}
+
+	res, err := s.getGravatar(context.Background(), "known", GetAvatarArgs{Size: 80, Default: "identicon"})
+	asserts.NoError(err)
+	asserts.Equal(SourceGravatar, res.Source)
+	asserts.Equal(2006, res.LastModified.Year())
+
+	res, err = s.getGravatar(context.Background(), "unknown", GetAvatarArgs{Size: 80, Default: "identicon"})
+	asserts.NoError(err)
+	asserts.Equal(SourceDefault, res.Source)
+
+	res, err = s.getGravatar(context.Background(), "unknown", GetAvatarArgs{Size: 80, Default: fallback.URL + "/default.png"})
+	asserts.NoError(err)
+	asserts.Equal(SourceDefault, res.Source)
+
+	res, err = s.getGravatar(context.Background(), "unknown", GetAvatarArgs{Size: 80})
+	asserts.NoError(err)
+	asserts.Nil(res.Avatar)
+
+	res, err = s.getGravatar(context.Background(), "known", GetAvatarArgs{Size: 80, Default: "identicon", ForceDefault: true})
+	asserts.NoError(err)
+	asserts.Equal(SourceDefault, res.Source)
+
+	// every lookup is a single request
+	asserts.Equal(5, requests)
+}
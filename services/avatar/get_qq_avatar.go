@@ -1,60 +1,53 @@
 package avatar
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
+	"sort"
 	"strconv"
 
-	"github.com/imroc/req/v3"
-	"github.com/nfnt/resize"
+	"github.com/gocql/gocql"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.uber.org/zap"
 )
 
-func initQQClient() *req.Client {
-	c := req.C().
-		EnableDumpEachRequest().
-		SetBaseURL("https://q.qlogo.cn/").
-		SetCommonHeader("Accept", "image/jpeg").
-		SetCommonQueryParams(map[string]string{
-			"dst_uin":  "0",
-			"spec":     "640",
-			"img_type": "jpg",
-		}).
-		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
-			if resp.Err != nil { // There is an underlying error, e.g. network error or unmarshal error (SetSuccessResult or SetErrorResult was invoked before).
-				if dump := resp.Dump(); dump != "" { // Append dump content to original underlying error to help troubleshoot.
-					resp.Err = fmt.Errorf("error: %s\nraw content:\n%s", resp.Err.Error(), resp.Dump())
-				}
-				return nil // Skip the following logic if there is an underlying error.
-			}
-
-			if !resp.IsSuccessState() {
-				resp.Err = fmt.Errorf("bad response, raw content:\n%s", resp.Dump())
-			}
-
-			return nil
-		}).
-		WrapRoundTripFunc(WithTracer)
-
-	return c
+// qqSpec picks the smallest qlogo spec covering the requested size, or the largest one.
+func qqSpec(specs []int, size int64) int {
+	i := sort.SearchInts(specs, int(min(size, int64(specs[len(specs)-1]))))
+	return specs[i]
 }
 
-func (s *service) getQQAvatar(ctx context.Context, hash string, args GetAvatarArgs) (image.Image, error) {
+// getQQAvatar returns a nil image without error when the hash has no qq mapping
+// or the qq account only has one of the default avatars, the qq number is returned when there is a mapping.
+// The returned image is shared with concurrent requests of the same account and must not be modified.
+func (s *service) getQQAvatar(ctx context.Context, hash string, size int64) (image.Image, int64, error) {
 	ctx, span := tracer.Start(ctx, "service.AvatarService.getQQAvatar")
 	defer span.End()
 
 	// get qq avatar
 	qqid, err := s.MD5QQMappingRepo.GetQQIdByEmailMD5(ctx, hash)
-	if err != nil {
+	if errors.Is(err, gocql.ErrNotFound) {
+		return nil, 0, nil
+	} else if err != nil {
 		otelzap.L().Ctx(ctx).Error("get qq id by email md5 failed", zap.Error(err))
-		return nil, err
+		return nil, 0, err
 	}
 
+	img, err := s.qqFetches.get(ctx, qqid, qqSpec(s.qqSpecs, size))
+	return img, qqid, err
+}
+
+func (s *service) downloadQQAvatar(ctx context.Context, qqid int64, spec int) (image.Image, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.downloadQQAvatar")
+	defer span.End()
+
 	resp, err := s.qqAvatarClient.R().
 		SetContext(ctx).
 		SetQueryParam("dst_uin", strconv.FormatInt(qqid, 10)).
+		SetQueryParam("spec", strconv.Itoa(spec)).
 		Get("headimg_dl")
 	if err != nil {
 		otelzap.L().Ctx(ctx).Error("download qq avatar failed", zap.Error(err))
@@ -64,15 +57,17 @@ func (s *service) getQQAvatar(ctx context.Context, hash string, args GetAvatarAr
 		return nil, fmt.Errorf("download qq avatar failed: %v", resp.ErrorResult())
 	}
 
-	defer resp.Body.Close()
-
-	img, err := parseImage(resp.GetHeader("Content-Type"), resp.Body)
+	content := resp.Bytes()
+	img, err := parseImage(resp.GetHeader("Content-Type"), bytes.NewReader(content))
 	if err != nil {
 		otelzap.L().Ctx(ctx).Error("parse image failed", zap.Error(err))
 		return nil, err
 	}
 
-	img = resize.Resize(uint(args.Size), uint(args.Size), img, resize.Lanczos3)
+	if s.defaultAvatars.isDefault(ctx, content, img) {
+		span.AddEvent("qq default avatar detected")
+		return nil, nil
+	}
 
 	return img, nil
 }
@@ -0,0 +1,58 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/lo"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/cdn"
+)
+
+// Invalidate drops the cached avatars of the hashes, ours and the CDN's, both are tried even when one fails.
+func (s *service) Invalidate(ctx context.Context, hashes ...string) error {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.Invalidate")
+	defer span.End()
+
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	var errs []error
+	if err := s.cache.invalidate(ctx, hashes...); err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("invalidate avatar cache failed", zap.Strings("hashes", hashes), zap.Error(err))
+		errs = append(errs, err)
+	}
+
+	if err := s.Purger.Purge(ctx, lo.Map(hashes, func(hash string, _ int) string { return cdn.SurrogateKey(hash) })...); err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("purge cdn failed", zap.Strings("hashes", hashes), zap.Error(err))
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// InsertMappings stores the mappings and then invalidates their hashes in one batch,
+// every mapping write goes through it so no cached avatar outlives its mapping.
+func (s *service) InsertMappings(ctx context.Context, mappings ...Mapping) error {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.InsertMappings")
+	defer span.End()
+
+	hashes := make([]string, 0, len(mappings))
+	for _, mapping := range mappings {
+		if err := s.MD5QQMappingRepo.InsertMapping(ctx, mapping.QQ, mapping.Hash); err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("insert mapping failed", zap.String("hash", mapping.Hash), zap.Error(err))
+			// the mappings written so far are invalidated all the same
+			return errors.Join(err, s.Invalidate(ctx, hashes...))
+		}
+
+		hashes = append(hashes, mapping.Hash)
+	}
+
+	return s.Invalidate(ctx, hashes...)
+}
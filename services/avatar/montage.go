@@ -0,0 +1,170 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"time"
+
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+)
+
+var ErrTooManyMembers = errors.New("too many montage members")
+
+// montageBorder outlines the circles of stacked members.
+var montageBorder = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+type MontageArgs struct {
+	// Size is the width of the montage, Shape is applied to the whole montage
+	// and the other options to every member.
+	GetAvatarArgs
+	Layout imaging.Layout
+}
+
+// GetMontage composites the avatars of the hashes into one image, members without an avatar are left out.
+// The returned avatar has no source, it is nil without error when none of the members has an avatar.
+func (s *service) GetMontage(ctx context.Context, hashes []string, args MontageArgs) (*Avatar, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.GetMontage")
+	defer span.End()
+
+	if len(hashes) > s.montageMaxMembers {
+		return nil, fmt.Errorf("%w: at most %d, got %d", ErrTooManyMembers, s.montageMaxMembers, len(hashes))
+	}
+	if args.Layout == imaging.LayoutGroup {
+		hashes = hashes[:min(len(hashes), imaging.GroupMembers)]
+	}
+
+	entry, err := s.cached(ctx, newMontageTask(hashes, args))
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.Avatar, nil
+}
+
+// montage builds the uncached montage, laid out for the members which have an avatar.
+func (s *service) montage(ctx context.Context, hashes []string, args MontageArgs) (*Avatar, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.montage")
+	defer span.End()
+
+	canvas, cells, members, err := s.resolveMembers(ctx, hashes, args)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	images := make([]image.Image, 0, len(members))
+	var lastModified time.Time
+	for _, member := range members {
+		img, err := png.Decode(bytes.NewReader(member.Data))
+		if err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("decode montage member failed", zap.Error(err))
+			return nil, err
+		}
+
+		images = append(images, img)
+		if member.LastModified.After(lastModified) {
+			lastModified = member.LastModified
+		}
+	}
+
+	img := imaging.Compose(args.Layout, canvas, cells, images, montageBorder)
+	return s.encodeAvatar(ctx, &resolvedImage{
+		img:          imaging.Shape(img, args.Shape),
+		lastModified: lastModified,
+		nativeSize:   img.Bounds().Size(),
+	}, args.GetAvatarArgs)
+}
+
+// resolveMembers gets the avatars of the members through the avatar cache, each in the size of its cell,
+// and returns the layout of the members which have one. The cells are laid out for every hash first,
+// when some are left out the members whose cell changed are fetched again in the size of their new cell.
+// Members failing to resolve are left out unless all of them fail.
+func (s *service) resolveMembers(ctx context.Context, hashes []string, args MontageArgs) (image.Rectangle, []image.Rectangle, []*Avatar, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.resolveMembers")
+	defer span.End()
+
+	canvas, cells := imaging.MontageCells(args.Layout, len(hashes), int(args.Size))
+	avatars, errs := s.memberAvatars(ctx, hashes, cells, args)
+
+	found := make([]string, 0, len(hashes))
+	members := make([]*Avatar, 0, len(hashes))
+	sizes := make([]int64, 0, len(hashes))
+	for i, member := range avatars {
+		if errs[i] != nil {
+			otelzap.L().Ctx(ctx).Warn("resolve montage member failed", zap.String("hash", hashes[i]), zap.Error(errs[i]))
+		} else if member != nil {
+			found = append(found, hashes[i])
+			members = append(members, member)
+			sizes = append(sizes, cellSize(cells[i]))
+		}
+	}
+
+	if len(members) == 0 {
+		return image.Rectangle{}, nil, nil, errors.Join(errs...)
+	} else if len(members) == len(hashes) {
+		return canvas, cells, members, nil
+	}
+
+	canvas, cells = imaging.MontageCells(args.Layout, len(found), int(args.Size))
+
+	var changed []int
+	for i, cell := range cells {
+		if cellSize(cell) != sizes[i] {
+			changed = append(changed, i)
+		}
+	}
+
+	refetch := make([]string, len(changed))
+	refetchCells := make([]image.Rectangle, len(changed))
+	for j, i := range changed {
+		refetch[j], refetchCells[j] = found[i], cells[i]
+	}
+
+	avatars, errs = s.memberAvatars(ctx, refetch, refetchCells, args)
+	for j, i := range changed {
+		// the avatar in the size of the old cell still covers the new one, scaled
+		if errs[j] != nil {
+			otelzap.L().Ctx(ctx).Warn("resolve montage member failed", zap.String("hash", found[i]), zap.Error(errs[j]))
+		} else if avatars[j] != nil {
+			members[i] = avatars[j]
+		}
+	}
+
+	return canvas, cells, members, nil
+}
+
+// memberAvatars gets the avatars of the hashes concurrently, each in the size of its cell.
+func (s *service) memberAvatars(ctx context.Context, hashes []string, cells []image.Rectangle, args MontageArgs) ([]*Avatar, []error) {
+	avatars := make([]*Avatar, len(hashes))
+	errs := make([]error, len(hashes))
+
+	var wg sync.WaitGroup
+	for i, cell := range cells {
+		wg.Add(1)
+		go func(i int, cell image.Rectangle) {
+			defer wg.Done()
+			avatars[i], errs[i] = s.GetAvatar(ctx, hashes[i], GetAvatarArgs{
+				Size:         cellSize(cell),
+				Default:      args.Default,
+				ForceDefault: args.ForceDefault,
+				Rating:       args.Rating,
+			})
+		}(i, cell)
+	}
+	wg.Wait()
+
+	return avatars, errs
+}
+
+func cellSize(cell image.Rectangle) int64 {
+	return int64(max(cell.Dx(), cell.Dy()))
+}
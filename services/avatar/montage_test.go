@@ -0,0 +1,68 @@
+package avatar
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+)
+
+func TestService_GetMontage(t *testing.T) {
+	asserts := assert.New(t)
+
+	var mu sync.Mutex
+	sizes := make(map[string][]string)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sizes[r.URL.Path] = append(sizes[r.URL.Path], r.URL.Query().Get("s"))
+		mu.Unlock()
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	}))
+	defer svr.Close()
+
+	s := &service{
+		MD5QQMappingRepo:  fakeMappingRepo{},
+		resizer:           &resizer{filter: resize.Bilinear, upscale: UpscaleAllow},
+		montageMaxMembers: 5,
+	}
+	_, s.gravatarClient = newTestUpstream(t, config.UpstreamConfig{}, "gravatar", svr.URL+"/")
+
+	args := MontageArgs{GetAvatarArgs: GetAvatarArgs{Size: 80}, Layout: imaging.LayoutGrid}
+
+	res, err := s.GetMontage(context.Background(), []string{"red", "white", "missing"}, args)
+	asserts.NoError(err)
+	asserts.Equal("image/png", res.ContentType)
+	// the missing member is left out, two members make one row
+	asserts.Equal(80, res.Width)
+	asserts.Equal(39, res.Height)
+
+	// five members make rows of three, the four found are laid out again in rows of two
+	res, err = s.GetMontage(context.Background(), []string{"a", "b", "c", "d", "missing"}, args)
+	asserts.NoError(err)
+	asserts.Equal(80, res.Width)
+	asserts.Equal(80, res.Height)
+	asserts.Equal([]string{"25", "39"}, sizes["/a"])
+
+	res, err = s.GetMontage(context.Background(), []string{"missing"}, args)
+	asserts.NoError(err)
+	asserts.Nil(res)
+
+	_, err = s.GetMontage(context.Background(), []string{"a", "b", "c", "d", "e", "f"}, args)
+	asserts.ErrorIs(err, ErrTooManyMembers)
+}
@@ -0,0 +1,88 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"image/png"
+	"time"
+
+	"github.com/nfnt/resize"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+)
+
+const (
+	// placeholderSampleSize is the size the image is scaled down to before it is analyzed.
+	placeholderSampleSize = 32
+	placeholderBlurHashX  = 4
+	placeholderBlurHashY  = 4
+)
+
+// Placeholder is a lightweight stand-in shown while the avatar loads.
+type Placeholder struct {
+	BlurHash      string `json:"blurhash"`
+	DominantColor string `json:"dominant_color"`
+	AverageColor  string `json:"average_color"`
+	// Preview is a tiny png of the avatar as data uri (LQIP).
+	Preview string `json:"preview"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+
+	Source       Source    `json:"source"`
+	LastModified time.Time `json:"-"`
+}
+
+func (s *service) GetPlaceholder(ctx context.Context, hash string, args GetAvatarArgs) (*Placeholder, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.GetPlaceholder")
+	defer span.End()
+
+	// the placeholder doesn't depend on the output format
+	args.EnableWebp = false
+
+	entry, err := s.cached(ctx, newRefreshTask(cacheKindPlaceholder, hash, args))
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.Placeholder, nil
+}
+
+func (s *service) newPlaceholder(ctx context.Context, resolved *resolvedImage) (*Placeholder, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.newPlaceholder")
+	defer span.End()
+
+	bounds := resolved.img.Bounds()
+	sample := resize.Thumbnail(placeholderSampleSize, placeholderSampleSize, resolved.img, resize.Bilinear)
+
+	blurHash, err := imaging.BlurHash(sample, placeholderBlurHashX, placeholderBlurHashY)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Error("encode blurhash failed", zap.Error(err))
+		return nil, err
+	}
+
+	var preview bytes.Buffer
+	if err := png.Encode(&preview, resize.Thumbnail(s.placeholderPreviewSize, s.placeholderPreviewSize, sample, resize.Bilinear)); err != nil {
+		otelzap.L().Ctx(ctx).Error("encode placeholder preview failed", zap.Error(err))
+		return nil, err
+	}
+
+	return &Placeholder{
+		BlurHash:      blurHash,
+		DominantColor: hexColor(imaging.DominantColor(sample)),
+		AverageColor:  hexColor(imaging.AverageColor(sample)),
+		Preview:       "data:image/png;base64," + base64.StdEncoding.EncodeToString(preview.Bytes()),
+		Width:         bounds.Dx(),
+		Height:        bounds.Dy(),
+		Source:        resolved.source,
+		LastModified:  resolved.lastModified,
+	}, nil
+}
+
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
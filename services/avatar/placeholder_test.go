@@ -0,0 +1,30 @@
+package avatar
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_NewPlaceholder(t *testing.T) {
+	asserts := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{R: 0xff, A: 0xff}), image.Point{}, draw.Src)
+
+	s := &service{placeholderPreviewSize: 16}
+	p, err := s.newPlaceholder(context.Background(), &resolvedImage{img: img, source: SourceQQ})
+	asserts.NoError(err)
+
+	asserts.Len(p.BlurHash, 4+2*(4*4))
+	asserts.Equal("#ff0000", p.DominantColor)
+	asserts.Equal("#ff0000", p.AverageColor)
+	asserts.True(strings.HasPrefix(p.Preview, "data:image/png;base64,"))
+	asserts.Equal(100, p.Width)
+	asserts.Equal(SourceQQ, p.Source)
+}
@@ -0,0 +1,159 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gocql/gocql"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+)
+
+type GetProfileArgs struct {
+	// AvatarURL is the url of this service's avatar of the hash, used by synthesized profiles.
+	AvatarURL string
+}
+
+// Profile is an entry of the gravatar profile api, `{"entry": [profile]}`.
+// Fields gravatar returns but this service doesn't know are dropped.
+type Profile struct {
+	XMLName           xml.Name       `json:"-" xml:"entry"`
+	ID                string         `json:"id" xml:"id"`
+	Hash              string         `json:"hash" xml:"hash"`
+	RequestHash       string         `json:"requestHash" xml:"requestHash"`
+	ProfileURL        string         `json:"profileUrl,omitempty" xml:"profileUrl,omitempty"`
+	PreferredUsername string         `json:"preferredUsername,omitempty" xml:"preferredUsername,omitempty"`
+	ThumbnailURL      string         `json:"thumbnailUrl" xml:"thumbnailUrl"`
+	Photos            []ProfilePhoto `json:"photos" xml:"photos"`
+	Name              *ProfileName   `json:"name,omitempty" xml:"name,omitempty"`
+	DisplayName       string         `json:"displayName" xml:"displayName"`
+	AboutMe           string         `json:"aboutMe,omitempty" xml:"aboutMe,omitempty"`
+	CurrentLocation   string         `json:"currentLocation,omitempty" xml:"currentLocation,omitempty"`
+	URLs              []ProfileURL   `json:"urls" xml:"urls"`
+
+	// Source is SourceGravatar for proxied profiles and SourceQQ for synthesized ones.
+	Source Source `json:"-" xml:"-"`
+}
+
+type ProfilePhoto struct {
+	Value string `json:"value" xml:"value"`
+	Type  string `json:"type" xml:"type"`
+}
+
+type ProfileURL struct {
+	Value string `json:"value" xml:"value"`
+	Title string `json:"title" xml:"title"`
+}
+
+type ProfileName struct {
+	GivenName  string `json:"givenName,omitempty" xml:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty" xml:"familyName,omitempty"`
+	Formatted  string `json:"formatted,omitempty" xml:"formatted,omitempty"`
+}
+
+// UnmarshalJSON accepts the empty array gravatar sends instead of an empty object.
+func (n *ProfileName) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		*n = ProfileName{}
+		return nil
+	}
+
+	type name ProfileName
+	return json.Unmarshal(data, (*name)(n))
+}
+
+type profileResponse struct {
+	Entry []Profile `json:"entry"`
+}
+
+// GetProfile proxies the gravatar profile of the hash, or synthesizes a profile for hashes mapped to a qq account.
+// A nil profile without error is returned when there is neither.
+func (s *service) GetProfile(ctx context.Context, hash string, args GetProfileArgs) (*Profile, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.GetProfile")
+	defer span.End()
+
+	entry, err := s.cached(ctx, newRefreshTask(cacheKindProfile, hash, GetAvatarArgs{}))
+	if err != nil || entry.Profile == nil {
+		return nil, err
+	}
+
+	if entry.Profile.Source != SourceQQ {
+		return entry.Profile, nil
+	}
+
+	// the avatar url depends on the caller, it is left out of the cached profile
+	profile := *entry.Profile
+	profile.ThumbnailURL = args.AvatarURL
+	profile.Photos = []ProfilePhoto{{Value: args.AvatarURL, Type: "thumbnail"}}
+
+	return &profile, nil
+}
+
+// profile builds the uncached profile of the hash, synthesized profiles have no avatar url.
+func (s *service) profile(ctx context.Context, hash string) (*Profile, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.profile")
+	defer span.End()
+
+	profile, err := s.getGravatarProfile(ctx, hash)
+	if err != nil || profile != nil {
+		return profile, err
+	}
+
+	_, err = s.MD5QQMappingRepo.GetQQIdByEmailMD5(ctx, hash)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("get qq id by email md5 failed", zap.Error(err))
+		return nil, err
+	}
+
+	// the qq number and nickname are not disclosed
+	return &Profile{
+		ID:          hash,
+		Hash:        hash,
+		RequestHash: hash,
+		DisplayName: s.qqDisplayName,
+		URLs:        []ProfileURL{},
+		Source:      SourceQQ,
+	}, nil
+}
+
+func (s *service) getGravatarProfile(ctx context.Context, hash string) (*Profile, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.getGravatarProfile")
+	defer span.End()
+
+	resp, err := s.profileClient.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetPathParam("hash", hash).
+		Get("{hash}.json")
+	if resp.GetStatusCode() == http.StatusNotFound {
+		return nil, nil
+	} else if err != nil {
+		otelzap.L().Ctx(ctx).Error("get gravatar profile failed", zap.Error(err))
+		return nil, err
+	} else if resp.IsErrorState() {
+		otelzap.L().Ctx(ctx).Error("get gravatar profile failed", zap.Error(resp.Err))
+		return nil, fmt.Errorf("get gravatar profile failed: %v", resp.ErrorResult())
+	}
+
+	var res profileResponse
+	if err := json.Unmarshal(resp.Bytes(), &res); err != nil {
+		otelzap.L().Ctx(ctx).Error("parse gravatar profile failed", zap.Error(err))
+		return nil, err
+	}
+
+	if len(res.Entry) == 0 {
+		return nil, nil
+	}
+
+	profile := res.Entry[0]
+	profile.Source = SourceGravatar
+
+	return &profile, nil
+}
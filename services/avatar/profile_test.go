@@ -0,0 +1,89 @@
+package avatar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+func TestService_GetProfile(t *testing.T) {
+	asserts := assert.New(t)
+
+	gravatar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/known.json" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`"User not found"`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"entry":[{"id":"1","hash":"known","requestHash":"known","profileUrl":"http://gravatar.com/known",` +
+			`"preferredUsername":"known","thumbnailUrl":"https://0.gravatar.com/avatar/known",` +
+			`"photos":[{"value":"https://0.gravatar.com/avatar/known","type":"thumbnail"}],` +
+			`"name":[],"displayName":"Known","urls":[]}]}`))
+	}))
+	defer gravatar.Close()
+
+	s := &service{
+		MD5QQMappingRepo: fakeMappingRepo{"qq": 1, "known": 2},
+		qqDisplayName:    "QQ User",
+	}
+	_, s.profileClient = newTestUpstream(t, config.UpstreamConfig{}, "gravatar", gravatar.URL+"/")
+
+	args := GetProfileArgs{AvatarURL: "https://avatar.example.com/avatar/qq"}
+
+	profile, err := s.GetProfile(context.Background(), "known", args)
+	asserts.NoError(err)
+	asserts.Equal(SourceGravatar, profile.Source)
+	asserts.Equal("Known", profile.DisplayName)
+	asserts.Equal("http://gravatar.com/known", profile.ProfileURL)
+	asserts.Equal(ProfileName{}, *profile.Name)
+
+	profile, err = s.GetProfile(context.Background(), "qq", args)
+	asserts.NoError(err)
+	asserts.Equal(SourceQQ, profile.Source)
+	asserts.Equal("QQ User", profile.DisplayName)
+	asserts.Equal(args.AvatarURL, profile.ThumbnailURL)
+	asserts.Equal([]ProfilePhoto{{Value: args.AvatarURL, Type: "thumbnail"}}, profile.Photos)
+
+	profile, err = s.GetProfile(context.Background(), "unknown", args)
+	asserts.NoError(err)
+	asserts.Nil(profile)
+}
+
+func TestService_GetProfileCached(t *testing.T) {
+	asserts := assert.New(t)
+
+	var hits atomic.Int32
+	gravatar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer gravatar.Close()
+
+	s := &service{
+		MD5QQMappingRepo: fakeMappingRepo{"qq": 1},
+		qqDisplayName:    "QQ User",
+		cache:            &memoryAvatarCache{entries: make(map[string]*cacheEntry)},
+		cachePolicy:      cachePolicy{enabled: true, ttl: time.Hour, notFoundTTL: time.Hour},
+	}
+	_, s.profileClient = newTestUpstream(t, config.UpstreamConfig{}, "gravatar", gravatar.URL+"/")
+
+	profile, err := s.GetProfile(context.Background(), "qq", GetProfileArgs{AvatarURL: "https://a.example.com/avatar/qq"})
+	asserts.NoError(err)
+	asserts.Equal("https://a.example.com/avatar/qq", profile.ThumbnailURL)
+
+	// the cached profile gets the avatar url of every caller
+	profile, err = s.GetProfile(context.Background(), "qq", GetProfileArgs{AvatarURL: "https://b.example.com/avatar/qq"})
+	asserts.NoError(err)
+	asserts.Equal("https://b.example.com/avatar/qq", profile.ThumbnailURL)
+	asserts.Equal([]ProfilePhoto{{Value: "https://b.example.com/avatar/qq", Type: "thumbnail"}}, profile.Photos)
+	asserts.EqualValues(1, hits.Load())
+}
@@ -0,0 +1,95 @@
+package avatar
+
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type qqFetch struct {
+	spec     int
+	fetching bool
+	done     chan struct{}
+
+	img image.Image
+	err error
+}
+
+// qqCoalescer downloads the avatar of an account once for concurrent requests of different sizes.
+// The first request starts the download right away and requests it covers join it, like a singleflight.
+// With a window, a request below the largest spec instead opens a batch and waits for the window,
+// requests joining meanwhile raise its spec to the largest one needed.
+type qqCoalescer struct {
+	mu       sync.Mutex
+	window   time.Duration
+	largest  int
+	inflight map[int64]*qqFetch
+	fetch    func(ctx context.Context, qqid int64, spec int) (image.Image, error)
+}
+
+func newQQCoalescer(window time.Duration, largest int, fetch func(ctx context.Context, qqid int64, spec int) (image.Image, error)) *qqCoalescer {
+	return &qqCoalescer{
+		window:   window,
+		largest:  largest,
+		inflight: make(map[int64]*qqFetch),
+		fetch:    fetch,
+	}
+}
+
+func (c *qqCoalescer) get(ctx context.Context, qqid int64, spec int) (image.Image, error) {
+	c.mu.Lock()
+	f, ok := c.inflight[qqid]
+	switch {
+	case ok && !f.fetching:
+		f.spec = max(f.spec, spec)
+	case ok && f.spec >= spec:
+	default:
+		// waiting is pointless when no request could raise the spec
+		f = &qqFetch{spec: spec, fetching: c.window <= 0 || spec >= c.largest, done: make(chan struct{})}
+		c.inflight[qqid] = f
+		// the download is shared, it must not fail when the request opening the batch goes away
+		go c.run(context.WithoutCancel(ctx), qqid, f)
+	}
+	c.mu.Unlock()
+
+	trace.SpanFromContext(ctx).AddEvent("qq.coalesce", trace.WithAttributes(
+		attribute.Int("spec", spec),
+		attribute.Bool("joined", ok),
+	))
+
+	select {
+	case <-f.done:
+		return f.img, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *qqCoalescer) run(ctx context.Context, qqid int64, f *qqFetch) {
+	c.mu.Lock()
+	batching := !f.fetching
+	c.mu.Unlock()
+
+	if batching {
+		time.Sleep(c.window)
+	}
+
+	c.mu.Lock()
+	f.fetching = true
+	spec := f.spec
+	c.mu.Unlock()
+
+	f.img, f.err = c.fetch(ctx, qqid, spec)
+
+	c.mu.Lock()
+	if c.inflight[qqid] == f {
+		delete(c.inflight, qqid)
+	}
+	c.mu.Unlock()
+
+	close(f.done)
+}
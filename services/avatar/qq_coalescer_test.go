@@ -0,0 +1,117 @@
+package avatar
+
+import (
+	"context"
+	"image"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQQSpec(t *testing.T) {
+	asserts := assert.New(t)
+
+	specs := []int{40, 100, 140, 640}
+	asserts.Equal(40, qqSpec(specs, 1))
+	asserts.Equal(40, qqSpec(specs, 40))
+	asserts.Equal(100, qqSpec(specs, 41))
+	asserts.Equal(640, qqSpec(specs, 200))
+	asserts.Equal(640, qqSpec(specs, 2048))
+}
+
+func TestQQCoalescer(t *testing.T) {
+	asserts := assert.New(t)
+
+	var fetches atomic.Int32
+	var fetchedSpec atomic.Int32
+	c := newQQCoalescer(50*time.Millisecond, 640, func(ctx context.Context, qqid int64, spec int) (image.Image, error) {
+		fetches.Add(1)
+		fetchedSpec.Store(int32(spec))
+		return image.NewNRGBA(image.Rect(0, 0, spec, spec)), nil
+	})
+
+	var wg sync.WaitGroup
+	for _, spec := range []int{40, 140, 100} {
+		wg.Add(1)
+		go func(spec int) {
+			defer wg.Done()
+
+			img, err := c.get(context.Background(), 10000, spec)
+			asserts.NoError(err)
+			asserts.Equal(140, img.Bounds().Dx())
+		}(spec)
+	}
+	wg.Wait()
+
+	asserts.EqualValues(1, fetches.Load())
+	asserts.EqualValues(140, fetchedSpec.Load())
+
+	// once the batch is done, the next request fetches again
+	img, err := c.get(context.Background(), 10000, 40)
+	asserts.NoError(err)
+	asserts.Equal(40, img.Bounds().Dx())
+	asserts.EqualValues(2, fetches.Load())
+}
+
+func TestQQCoalescer_NoWindow(t *testing.T) {
+	asserts := assert.New(t)
+
+	started := make(chan int, 2)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	c := newQQCoalescer(0, 640, func(ctx context.Context, qqid int64, spec int) (image.Image, error) {
+		fetches.Add(1)
+		started <- spec
+		<-release
+		return image.NewNRGBA(image.Rect(0, 0, spec, spec)), nil
+	})
+
+	var wg sync.WaitGroup
+	get := func(spec, want int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			img, err := c.get(context.Background(), 10000, spec)
+			asserts.NoError(err)
+			asserts.Equal(want, img.Bounds().Dx())
+		}()
+	}
+
+	// the download starts without waiting for other requests
+	get(100, 100)
+	asserts.Equal(100, <-started)
+
+	// a larger request starts its own, a request it covers joins the latest one
+	get(640, 640)
+	asserts.Equal(640, <-started)
+	get(40, 640)
+
+	// let the last request join before the downloads finish
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	asserts.EqualValues(2, fetches.Load())
+}
+
+func TestQQCoalescer_CancelledWaiter(t *testing.T) {
+	asserts := assert.New(t)
+
+	c := newQQCoalescer(50*time.Millisecond, 640, func(ctx context.Context, qqid int64, spec int) (image.Image, error) {
+		return image.NewNRGBA(image.Rect(0, 0, spec, spec)), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.get(ctx, 10000, 40)
+	asserts.ErrorIs(err, context.Canceled)
+
+	// the download of the batch is not cancelled with the request that opened it
+	img, err := c.get(context.Background(), 10000, 40)
+	asserts.NoError(err)
+	asserts.Equal(40, img.Bounds().Dx())
+}
@@ -0,0 +1,166 @@
+package avatar
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AH-dark/bytestring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+)
+
+type refreshTask struct {
+	kind string
+	key  string
+	hash string
+	args GetAvatarArgs
+
+	// members and layout are only set for montages, whose hash identifies the list of members
+	members []string
+	layout  imaging.Layout
+}
+
+func newRefreshTask(kind string, hash string, args GetAvatarArgs) refreshTask {
+	return refreshTask{
+		kind: kind,
+		key:  cacheKey(kind, hash, args),
+		hash: hash,
+		args: args,
+	}
+}
+
+// newMontageTask identifies a montage by the digest of its layout and members, in their order.
+func newMontageTask(hashes []string, args MontageArgs) refreshTask {
+	id := ContentHash(bytestring.StringToBytes(string(args.Layout) + ":" + strings.Join(hashes, ",")))
+	task := newRefreshTask(cacheKindMontage, id, args.GetAvatarArgs)
+	task.members = hashes
+	task.layout = args.Layout
+
+	return task
+}
+
+// refreshQueue revalidates stale cache entries in the background with a fixed number of workers.
+// Tasks are dropped instead of blocking the request when the queue is full, and a key is only queued once.
+type refreshQueue struct {
+	tasks   chan refreshTask
+	pending sync.Map
+	workers int
+	timeout time.Duration
+	refresh func(ctx context.Context, task refreshTask) error
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	dropped   prometheus.Counter
+	refreshed *prometheus.CounterVec
+}
+
+func newRefreshQueue(registry *prometheus.Registry, size int, workers int, timeout time.Duration, refresh func(ctx context.Context, task refreshTask) error) (*refreshQueue, error) {
+	q := &refreshQueue{
+		tasks:   make(chan refreshTask, size),
+		workers: workers,
+		timeout: timeout,
+		refresh: refresh,
+		stop:    make(chan struct{}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "avatar_cache_refresh_dropped_total",
+			Help: "Number of stale cache entries not refreshed because the refresh queue was full.",
+		}),
+		refreshed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "avatar_cache_refresh_total",
+			Help: "Number of background cache refreshes by result.",
+		}, []string{"result"}),
+	}
+
+	if err := registry.Register(q.dropped); err != nil {
+		return nil, err
+	}
+	if err := registry.Register(q.refreshed); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func (q *refreshQueue) enqueue(task refreshTask) bool {
+	if _, loaded := q.pending.LoadOrStore(task.key, struct{}{}); loaded {
+		return true
+	}
+
+	select {
+	case q.tasks <- task:
+		return true
+	default:
+		q.pending.Delete(task.key)
+		q.dropped.Inc()
+		return false
+	}
+}
+
+func (q *refreshQueue) start() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// shutdown stops the workers after their current task, queued tasks are abandoned.
+func (q *refreshQueue) shutdown(ctx context.Context) error {
+	close(q.stop)
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *refreshQueue) work() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case task := <-q.tasks:
+			q.run(task)
+		}
+	}
+}
+
+func (q *refreshQueue) run(task refreshTask) {
+	defer q.pending.Delete(task.key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "service.AvatarService.refreshQueue.run")
+	defer span.End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			q.refreshed.WithLabelValues("panic").Inc()
+			otelzap.L().Ctx(ctx).Error("refresh avatar cache panicked", zap.String("key", task.key), zap.Any("panic", r))
+		}
+	}()
+
+	if err := q.refresh(ctx, task); err != nil {
+		q.refreshed.WithLabelValues("error").Inc()
+		otelzap.L().Ctx(ctx).Warn("refresh avatar cache failed", zap.String("key", task.key), zap.Error(err))
+		return
+	}
+
+	q.refreshed.WithLabelValues("success").Inc()
+}
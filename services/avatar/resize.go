@@ -0,0 +1,100 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/nfnt/resize"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+)
+
+var ErrUpscaleRejected = errors.New("requested size exceeds the source image")
+
+// upscaleRejectedError restores an ErrUpscaleRejected from the message of a cache entry.
+type upscaleRejectedError string
+
+func (e upscaleRejectedError) Error() string {
+	return string(e)
+}
+
+func (e upscaleRejectedError) Unwrap() error {
+	return ErrUpscaleRejected
+}
+
+const (
+	// UpscaleAllow scales small sources up to the requested size.
+	UpscaleAllow = "allow"
+	// UpscaleCap serves small sources at their own size.
+	UpscaleCap = "cap"
+	// UpscaleReject fails requests larger than the source with ErrUpscaleRejected.
+	UpscaleReject = "reject"
+)
+
+type resizer struct {
+	filter  resize.InterpolationFunction
+	linear  bool
+	upscale string
+}
+
+func newResizer(ctx context.Context, conf config.ResizeConfig) (*resizer, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.newResizer")
+	defer span.End()
+
+	filter, err := imaging.ParseFilter(conf.Filter)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("invalid resize filter", zap.Error(err))
+		return nil, err
+	}
+
+	r := &resizer{
+		filter:  filter,
+		linear:  conf.Linear,
+		upscale: conf.Upscale,
+	}
+
+	switch r.upscale {
+	case UpscaleAllow, UpscaleCap, UpscaleReject:
+	default:
+		err := fmt.Errorf("upscale policy %s not supported", r.upscale)
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("invalid upscale policy", zap.Error(err))
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// resize scales the avatar to a size x size square, following the upscale policy when the source is smaller.
+func (r *resizer) resize(ctx context.Context, img image.Image, size int64) (image.Image, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.resize")
+	defer span.End()
+
+	bounds := img.Bounds()
+	if int64(bounds.Dx()) == size && int64(bounds.Dy()) == size {
+		return img, nil
+	}
+
+	if source := int64(min(bounds.Dx(), bounds.Dy())); size > source {
+		switch r.upscale {
+		case UpscaleCap:
+			size = source
+		case UpscaleReject:
+			return nil, fmt.Errorf("%w: %d exceeds %d", ErrUpscaleRejected, size, source)
+		}
+	}
+
+	otelzap.L().Ctx(ctx).Debug("resize avatar",
+		zap.Int("width", bounds.Dx()),
+		zap.Int("height", bounds.Dy()),
+		zap.Int64("size", size),
+	)
+
+	return imaging.Resize(img, uint(size), uint(size), r.filter, r.linear), nil
+}
@@ -0,0 +1,43 @@
+package avatar
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+func TestResizer_Resize(t *testing.T) {
+	asserts := assert.New(t)
+
+	conf := config.ResizeConfig{Filter: "bilinear", Upscale: UpscaleCap}
+
+	r, err := newResizer(context.Background(), conf)
+	asserts.NoError(err)
+
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+
+	img, err := r.resize(context.Background(), src, 20)
+	asserts.NoError(err)
+	asserts.Equal(image.Rect(0, 0, 20, 20), img.Bounds())
+
+	img, err = r.resize(context.Background(), src, 100)
+	asserts.NoError(err)
+	asserts.Equal(image.Rect(0, 0, 40, 40), img.Bounds())
+
+	r.upscale = UpscaleAllow
+	img, err = r.resize(context.Background(), src, 100)
+	asserts.NoError(err)
+	asserts.Equal(image.Rect(0, 0, 100, 100), img.Bounds())
+
+	r.upscale = UpscaleReject
+	_, err = r.resize(context.Background(), src, 100)
+	asserts.ErrorIs(err, ErrUpscaleRejected)
+
+	conf.Upscale = "unknown"
+	_, err = newResizer(context.Background(), conf)
+	asserts.Error(err)
+}
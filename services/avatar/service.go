@@ -2,19 +2,27 @@ package avatar
 
 import (
 	"context"
-	"github.com/samber/lo"
+	"errors"
+	"image"
 	"image/png"
+	"sort"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/common/bytebufferpool"
 	"github.com/imroc/req/v3"
 	"github.com/kolesa-team/go-webp/webp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/lo"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
 	"github.com/AH-dark/gravatar-with-qq-avatar/database/dal"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/imaging"
+	"github.com/AH-dark/gravatar-with-qq-avatar/services/cdn"
 )
 
 var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/services/avatar")
@@ -25,51 +33,428 @@ type GetAvatarArgs struct {
 	ForceDefault bool
 	Rating       string
 
+	// Shape is applied to the avatar right before it is encoded.
+	Shape imaging.ShapeOptions
+
 	EnableWebp bool
 }
 
+// Source tells which upstream an avatar was served from.
+type Source string
+
+const (
+	SourceQQ       Source = "qq"
+	SourceGravatar Source = "gravatar"
+	// SourceDefault is the default image gravatar renders for the `d` parameter when the hash has no avatar.
+	SourceDefault Source = "default"
+)
+
+type Avatar struct {
+	Data         []byte
+	ContentType  string
+	ContentHash  string // hex SHA-256 of Data
+	ETag         string // strong entity tag derived from ContentHash, quoted
+	Width        int
+	Height       int
+	LastModified time.Time
+	Source       Source
+
+	// NativeWidth and NativeHeight are the size of the image downloaded from the source.
+	NativeWidth  int
+	NativeHeight int
+	// QQ is the qq number the hash is mapped to, even when the avatar came from another source.
+	// It must not be shown to unauthorized callers.
+	QQ int64
+}
+
+// Mapping maps the md5 of an email to a qq number.
+type Mapping struct {
+	Hash string
+	QQ   int64
+}
+
 type Service interface {
-	GetAvatar(ctx context.Context, hash string, args GetAvatarArgs) ([]byte, time.Time, error)
+	// GetAvatar returns a nil avatar without error when neither qq nor gravatar have one.
+	GetAvatar(ctx context.Context, hash string, args GetAvatarArgs) (*Avatar, error)
+	// CachedAvatar returns the avatar GetAvatar would serve from the cache, without fetching it,
+	// ok is false when it isn't cached.
+	CachedAvatar(ctx context.Context, hash string, args GetAvatarArgs) (res *Avatar, ok bool)
+	// GetPlaceholder describes the avatar GetAvatar would return, for showing while it loads.
+	GetPlaceholder(ctx context.Context, hash string, args GetAvatarArgs) (*Placeholder, error)
+	// Exists tells which source has an avatar for the hash without downloading or processing the image,
+	// the returned source is empty when there is none.
+	Exists(ctx context.Context, hash string, args GetAvatarArgs) (Source, error)
+	// GetProfile returns a gravatar compatible profile, nil without error when the hash has none.
+	GetProfile(ctx context.Context, hash string, args GetProfileArgs) (*Profile, error)
+	// GetMontage composites the avatars of several hashes into one image.
+	GetMontage(ctx context.Context, hashes []string, args MontageArgs) (*Avatar, error)
+	// Invalidate drops the cached avatars of the hashes, in redis and in the CDN, after their mappings changed.
+	Invalidate(ctx context.Context, hashes ...string) error
+	// InsertMappings writes email hash to qq mappings and invalidates the hashes.
+	InsertMappings(ctx context.Context, mappings ...Mapping) error
+	// CheckUpstreams reports, per upstream, whether requests can currently be sent to it,
+	// judged by the circuit breakers so no request is sent.
+	CheckUpstreams(ctx context.Context) map[string]error
 }
 
 type service struct {
 	fx.In            `ignore-unexported:"true"`
+	Config           *config.Config
+	Lifecycle        fx.Lifecycle
+	PromRegistry     *prometheus.Registry
+	Redis            redis.UniversalClient
 	MD5QQMappingRepo dal.MD5QQMappingRepo
+	Reloader         *config.Reloader
+	Purger           cdn.Purger
 
 	qqAvatarClient *req.Client
 	gravatarClient *req.Client
+	profileClient  *req.Client
+	upstreams      []*upstream
+	qqDisplayName  string
+	qqProbeSpec    string
+	qqSpecs        []int
+	qqFetches      *qqCoalescer
+	defaultAvatars *defaultAvatarDetector
+	resizer        *resizer
+
+	placeholderPreviewSize uint
+	montageMaxMembers      int
+
+	cache        avatarCache
+	cachePolicy  cachePolicy
+	refreshQueue *refreshQueue
+}
+
+func NewService(ctx context.Context, s service) (Service, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.NewService")
+	defer span.End()
+
+	metrics, err := newUpstreamMetrics(s.PromRegistry)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("register upstream metrics failed", zap.Error(err))
+		return nil, err
+	}
+
+	qqConf, gravatarConf := s.Config.Upstreams.QQ, s.Config.Upstreams.Gravatar
+
+	qqUpstream, err := newUpstream(ctx, qqConf.UpstreamConfig, metrics, "qq")
+	if err != nil {
+		return nil, err
+	}
+
+	gravatarUpstream, err := newUpstream(ctx, gravatarConf.UpstreamConfig, metrics, "gravatar")
+	if err != nil {
+		return nil, err
+	}
+
+	if s.qqAvatarClient, err = newUpstreamClient(ctx, qqConf.UpstreamConfig, s.Config.Debug, qqUpstream); err != nil {
+		return nil, err
+	}
+
+	if s.gravatarClient, err = newUpstreamClient(ctx, gravatarConf.UpstreamConfig, s.Config.Debug, gravatarUpstream); err != nil {
+		return nil, err
+	}
+
+	// profiles are served by the gravatar host under another path, with the same client settings
+	profileUpstream, err := newUpstreamWithEndpoints(ctx, gravatarConf.UpstreamConfig, metrics, "gravatar_profile", "gravatar", append(
+		[]string{gravatarConf.Profile.BaseURL},
+		gravatarConf.Profile.Mirrors...,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.profileClient, err = newUpstreamClient(ctx, gravatarConf.UpstreamConfig, s.Config.Debug, profileUpstream); err != nil {
+		return nil, err
+	}
+	s.qqDisplayName = s.Config.Profiles.QQDisplayName
+	s.upstreams = []*upstream{qqUpstream, gravatarUpstream, profileUpstream}
+	s.Reloader.Subscribe("upstreams", []string{"upstreams.qq.timeout", "upstreams.gravatar.timeout"}, s.applyUpstreamTimeouts)
+
+	s.qqProbeSpec = qqConf.ProbeSpec
+	s.qqSpecs = append([]int(nil), qqConf.Specs...)
+	sort.Ints(s.qqSpecs)
+	s.qqFetches = newQQCoalescer(qqConf.CoalesceWindow, s.qqSpecs[len(s.qqSpecs)-1], s.downloadQQAvatar)
+
+	if s.defaultAvatars, err = newDefaultAvatarDetector(ctx, qqConf.DefaultAvatars); err != nil {
+		return nil, err
+	}
+
+	if s.resizer, err = newResizer(ctx, s.Config.Images.Resize); err != nil {
+		return nil, err
+	}
+
+	s.placeholderPreviewSize = s.Config.Images.Placeholder.PreviewSize
+	s.montageMaxMembers = s.Config.Images.Montage.MaxMembers
+
+	s.cache = &redisAvatarCache{rdb: s.Redis}
+	s.cachePolicy = newCachePolicy(s.Config.Cache)
+	s.refreshQueue, err = newRefreshQueue(
+		s.PromRegistry,
+		s.Config.Cache.Refresh.QueueSize,
+		s.Config.Cache.Refresh.Workers,
+		s.Config.Cache.Refresh.Timeout,
+		s.refresh,
+	)
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("register cache refresh metrics failed", zap.Error(err))
+		return nil, err
+	}
+
+	s.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			s.refreshQueue.start()
+			return nil
+		},
+		OnStop: s.refreshQueue.shutdown,
+	})
+
+	return &s, nil
 }
 
-func NewService(s service) Service {
-	s.qqAvatarClient = initQQClient()
-	s.gravatarClient = initGravatarClient()
+func (s *service) CheckUpstreams(ctx context.Context) map[string]error {
+	_, span := tracer.Start(ctx, "service.AvatarService.CheckUpstreams")
+	defer span.End()
+
+	res := make(map[string]error, len(s.upstreams))
+	for _, u := range s.upstreams {
+		res[u.name] = u.available()
+	}
 
-	return &s
+	return res
 }
 
-func (s *service) GetAvatar(ctx context.Context, hash string, args GetAvatarArgs) ([]byte, time.Time, error) {
+func (s *service) GetAvatar(ctx context.Context, hash string, args GetAvatarArgs) (*Avatar, error) {
 	ctx, span := tracer.Start(ctx, "service.AvatarService.GetAvatar")
 	defer span.End()
 
-	var lastModified time.Time
+	entry, err := s.cached(ctx, newRefreshTask(cacheKindAvatar, hash, args))
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.Avatar, nil
+}
+
+func (s *service) CachedAvatar(ctx context.Context, hash string, args GetAvatarArgs) (*Avatar, bool) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.CachedAvatar")
+	defer span.End()
 
-	// get qq avatar
-	img, err := s.getQQAvatar(ctx, hash, args)
+	if !s.cachePolicy.enabled {
+		return nil, false
+	}
+
+	key := cacheKey(cacheKindAvatar, hash, args)
+	entry, err := s.cache.get(ctx, key)
 	if err != nil {
+		otelzap.L().Ctx(ctx).Warn("get avatar from cache failed", zap.String("key", key), zap.Error(err))
+		return nil, false
+	}
+
+	// stale entries are what GetAvatar serves while refreshing them
+	if entry == nil || !entry.revalidatable(time.Now()) || entry.UpscaleRejected != "" {
+		return nil, false
+	}
+
+	return entry.Avatar, true
+}
+
+// cached serves the cache entry of the task, fetching it on a miss and refreshing it in the background when stale.
+// A cached upscale rejection is returned as ErrUpscaleRejected.
+func (s *service) cached(ctx context.Context, task refreshTask) (*cacheEntry, error) {
+	entry, err := s.lookup(ctx, task)
+	if err == nil && entry.UpscaleRejected != "" {
+		return nil, upscaleRejectedError(entry.UpscaleRejected)
+	}
+
+	return entry, err
+}
+
+func (s *service) lookup(ctx context.Context, task refreshTask) (*cacheEntry, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.lookup")
+	defer span.End()
+
+	if !s.cachePolicy.enabled {
+		return s.fetch(ctx, task)
+	}
+
+	entry, err := s.cache.get(ctx, task.key)
+	if err != nil {
+		otelzap.L().Ctx(ctx).Warn("get avatar from cache failed", zap.String("key", task.key), zap.Error(err))
+		entry = nil
+	}
+
+	now := time.Now()
+	if entry != nil && entry.fresh(now) {
+		span.AddEvent("cache.hit")
+		return entry, nil
+	}
+
+	if entry != nil && entry.revalidatable(now) {
+		// serve the stale entry right away, the next visitors get the refreshed one
+		span.AddEvent("cache.stale")
+		s.refreshQueue.enqueue(task)
+		return entry, nil
+	}
+
+	res, err := s.fetch(ctx, task)
+	if err == nil && res.degraded {
+		// the fallback of a failed qq lookup is served, but neither cached nor preferred over a stale entry
+		err = errDegraded
+		if entry == nil || !entry.usableOnError(now) {
+			span.AddEvent("cache.degraded")
+			return res, nil
+		}
+	}
+	if err != nil {
+		if entry != nil && entry.usableOnError(now) {
+			otelzap.L().Ctx(ctx).Warn("fetch avatar failed, serving stale cache entry", zap.String("key", task.key), zap.Error(err))
+			span.AddEvent("cache.stale_if_error")
+			return entry, nil
+		}
+
+		return nil, err
+	}
+
+	if err := s.cache.set(ctx, task.hash, task.key, s.cachePolicy.newEntry(res, now), task.members...); err != nil {
+		otelzap.L().Ctx(ctx).Warn("set avatar cache failed", zap.String("key", task.key), zap.Error(err))
+	}
+
+	return res, nil
+}
+
+// refresh revalidates a stale cache entry, it runs on the refresh queue workers.
+func (s *service) refresh(ctx context.Context, task refreshTask) error {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.refresh")
+	defer span.End()
+
+	res, err := s.fetch(ctx, task)
+	if err != nil {
+		return err
+	} else if res.degraded {
+		// keep the stale entry rather than replacing it with the fallback
+		return errDegraded
+	}
+
+	return s.cache.set(ctx, task.hash, task.key, s.cachePolicy.newEntry(res, time.Now()), task.members...)
+}
+
+// fetch builds the uncached entry of the task, without timestamps. Sizes the upscale policy rejects
+// are an entry rather than an error, so they are cached like a miss.
+func (s *service) fetch(ctx context.Context, task refreshTask) (*cacheEntry, error) {
+	entry, err := s.build(ctx, task)
+	if errors.Is(err, ErrUpscaleRejected) {
+		return &cacheEntry{UpscaleRejected: err.Error()}, nil
+	}
+
+	return entry, err
+}
+
+func (s *service) build(ctx context.Context, task refreshTask) (*cacheEntry, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.build")
+	defer span.End()
+
+	switch task.kind {
+	case cacheKindMontage:
+		montage, err := s.montage(ctx, task.members, MontageArgs{GetAvatarArgs: task.args, Layout: task.layout})
+		return &cacheEntry{Avatar: montage}, err
+	case cacheKindProfile:
+		profile, err := s.profile(ctx, task.hash)
+		return &cacheEntry{Profile: profile}, err
+	}
+
+	resolved, err := s.resolveImage(ctx, task.hash, task.args)
+	if err != nil || resolved == nil {
+		return &cacheEntry{}, err
+	}
+
+	switch task.kind {
+	case cacheKindPlaceholder:
+		placeholder, err := s.newPlaceholder(ctx, resolved)
+		return &cacheEntry{Placeholder: placeholder, degraded: resolved.degraded}, err
+	default:
+		avatar, err := s.encodeAvatar(ctx, resolved, task.args)
+		return &cacheEntry{Avatar: avatar, degraded: resolved.degraded}, err
+	}
+}
+
+type resolvedImage struct {
+	img          image.Image
+	source       Source
+	lastModified time.Time
+	nativeSize   image.Point
+	qq           int64
+	// degraded is set when qq failed and the image is the fallback, which must not be cached
+	degraded bool
+}
+
+// resolveImage finds the avatar of the hash and brings it into the requested size and shape,
+// it returns nil without error when there is none.
+func (s *service) resolveImage(ctx context.Context, hash string, args GetAvatarArgs) (*resolvedImage, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.resolveImage")
+	defer span.End()
+
+	source := SourceQQ
+	var lastModified time.Time
+
+	// get qq avatar, fall through to gravatar on miss
+	img, qq, qqErr := s.getQQAvatar(ctx, hash, args.Size)
+	if qqErr != nil {
+		otelzap.L().Ctx(ctx).Warn("get qq avatar failed, falling back to gravatar", zap.Error(qqErr))
+	}
+
+	if img == nil {
 		res, err := s.getGravatar(ctx, hash, args)
 		if err != nil {
 			otelzap.L().Ctx(ctx).Warn("get gravatar failed", zap.Error(err))
-			return nil, time.Time{}, err
+			return nil, err
 		}
 
 		if lo.IsEmpty(res) {
-			return nil, time.Time{}, nil
+			// while qq fails a miss can't be told apart from an outage
+			if qqErr != nil {
+				return nil, qqErr
+			}
+
+			return nil, nil
 		}
 
 		img = res.Avatar
+		source = res.Source
 		lastModified = res.LastModified
 	}
 
+	nativeSize := img.Bounds().Size()
+	img, err := s.resizer.resize(ctx, img, args.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// the shape is given in pixels of the requested size, a capped upscale serves a smaller one
+	shape := args.Shape
+	if served := img.Bounds().Dx(); int64(served) != args.Size {
+		shape = shape.Scale(float64(served) / float64(args.Size))
+	}
+
+	return &resolvedImage{
+		img:          imaging.Shape(img, shape),
+		source:       source,
+		lastModified: lastModified,
+		nativeSize:   nativeSize,
+		qq:           qq,
+		degraded:     qqErr != nil,
+	}, nil
+}
+
+func (s *service) encodeAvatar(ctx context.Context, resolved *resolvedImage, args GetAvatarArgs) (*Avatar, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.encodeAvatar")
+	defer span.End()
+
+	img := resolved.img
+
 	// encode image
 	b := bytebufferpool.Get()
 	defer bytebufferpool.Put(b)
@@ -77,14 +462,46 @@ func (s *service) GetAvatar(ctx context.Context, hash string, args GetAvatarArgs
 	if args.EnableWebp {
 		if err := webp.Encode(b, img, nil); err != nil {
 			otelzap.L().Ctx(ctx).Error("encode webp failed", zap.Error(err))
-			return nil, time.Time{}, err
+			return nil, err
 		}
 	} else {
 		if err := png.Encode(b, img); err != nil {
 			otelzap.L().Ctx(ctx).Error("encode png failed", zap.Error(err))
-			return nil, time.Time{}, err
+			return nil, err
 		}
 	}
 
-	return b.Bytes(), lastModified, nil
+	// the buffer goes back to the pool, so the result needs its own copy
+	data := make([]byte, b.Len())
+	copy(data, b.Bytes())
+
+	sum := ContentHash(data)
+	return &Avatar{
+		Data:         data,
+		ContentType:  lo.If(args.EnableWebp, "image/webp").Else("image/png"),
+		ContentHash:  sum,
+		ETag:         `"` + sum + `"`,
+		Width:        img.Bounds().Dx(),
+		Height:       img.Bounds().Dy(),
+		LastModified: resolved.lastModified,
+		Source:       resolved.source,
+		NativeWidth:  resolved.nativeSize.X,
+		NativeHeight: resolved.nativeSize.Y,
+		QQ:           resolved.qq,
+	}, nil
+}
+
+// applyUpstreamTimeouts switches the upstreams to the changed `upstreams.<section>.timeout`.
+func (s *service) applyUpstreamTimeouts(ctx context.Context, event config.ChangeEvent) (func(), error) {
+	timeouts := make([]time.Duration, len(s.upstreams))
+	for i, u := range s.upstreams {
+		section, _ := event.Config.Upstreams.Section(u.section)
+		timeouts[i] = section.Timeout
+	}
+
+	return func() {
+		for i, u := range s.upstreams {
+			u.timeout.Store(int64(timeouts[i]))
+		}
+	}, nil
 }
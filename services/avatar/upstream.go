@@ -0,0 +1,278 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/imroc/req/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+var ErrUpstreamUnavailable = errors.New("all upstream endpoints are unavailable")
+
+type upstreamEndpoint struct {
+	url *url.URL
+	// name is the base url, mirrors may share the host of the primary endpoint
+	name    string
+	breaker *gobreaker.TwoStepCircuitBreaker
+}
+
+// upstream is an avatar host with an ordered list of endpoints, the first one being the primary
+// and the rest being mirrors. Every endpoint is guarded by its own circuit breaker.
+type upstream struct {
+	name string
+	// section names the `upstreams.<section>` config the upstream is built from,
+	// upstreams of the same host share it.
+	section   string
+	endpoints []*upstreamEndpoint
+
+	retry     retryPolicy
+	hedge     hedgePolicy
+	latencies *latencyWindow
+
+	maxBodySize int64
+	// timeout bounds a whole request with its retries and redirects, it is changed by config reloads
+	timeout atomic.Int64
+}
+
+func newUpstream(ctx context.Context, conf config.UpstreamConfig, metrics *upstreamMetrics, name string) (*upstream, error) {
+	urls := append([]string{conf.BaseURL}, conf.Mirrors...)
+	return newUpstreamWithEndpoints(ctx, conf, metrics, name, name, urls)
+}
+
+// newUpstreamWithEndpoints builds an upstream of the given endpoints which otherwise uses the `upstreams.<section>` config.
+func newUpstreamWithEndpoints(ctx context.Context, conf config.UpstreamConfig, metrics *upstreamMetrics, name, section string, urls []string) (*upstream, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.newUpstream")
+	defer span.End()
+
+	u := &upstream{
+		name:      name,
+		section:   section,
+		retry:     newRetryPolicy(conf.Retry),
+		hedge:     newHedgePolicy(conf.Hedge),
+		latencies: newLatencyWindow(conf.Hedge.Window),
+
+		maxBodySize: conf.MaxBodySize,
+	}
+	u.timeout.Store(int64(conf.Timeout))
+
+	for _, rawURL := range urls {
+		endpointURL, err := url.Parse(rawURL)
+		if err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("parse upstream endpoint failed", zap.String("upstream", name), zap.Error(err))
+			return nil, err
+		}
+
+		u.endpoints = append(u.endpoints, &upstreamEndpoint{
+			url:     endpointURL,
+			name:    endpointURL.String(),
+			breaker: gobreaker.NewTwoStepCircuitBreaker(breakerSettings(conf.Breaker, metrics, name, endpointURL.String())),
+		})
+	}
+
+	metrics.addUpstream(u)
+
+	return u, nil
+}
+
+func breakerSettings(conf config.BreakerConfig, metrics *upstreamMetrics, name, endpoint string) gobreaker.Settings {
+	return gobreaker.Settings{
+		Name:        endpoint,
+		MaxRequests: conf.MaxRequests,
+		Interval:    conf.Interval,
+		Timeout:     conf.Timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			if conf.ConsecutiveFailures > 0 && counts.ConsecutiveFailures >= conf.ConsecutiveFailures {
+				return true
+			}
+
+			return conf.FailureRatio > 0 && counts.Requests >= conf.MinRequests &&
+				float64(counts.TotalFailures)/float64(counts.Requests) >= conf.FailureRatio
+		},
+		OnStateChange: func(endpoint string, from gobreaker.State, to gobreaker.State) {
+			otelzap.L().Warn("upstream circuit breaker state changed",
+				zap.String("upstream", name),
+				zap.String("endpoint", endpoint),
+				zap.Stringer("from", from),
+				zap.Stringer("to", to),
+			)
+			metrics.transitions.WithLabelValues(name, endpoint, from.String(), to.String()).Inc()
+		},
+	}
+}
+
+// rewrite points the request, which is built against the primary endpoint, to the given endpoint.
+func (u *upstream) rewrite(r *http.Request, endpoint *upstreamEndpoint) *http.Request {
+	primary := u.endpoints[0].url
+	if endpoint.url == primary {
+		return r
+	}
+
+	target := *r.URL
+	target.Scheme = endpoint.url.Scheme
+	target.Host = endpoint.url.Host
+	target.Path = endpoint.url.Path + strings.TrimPrefix(r.URL.Path, primary.Path)
+	target.RawPath = ""
+
+	r = r.Clone(r.Context())
+	r.URL = &target
+	r.Host = target.Host
+
+	return r
+}
+
+// available fails when the circuit breakers of all endpoints are open, so no request would be sent.
+func (u *upstream) available() error {
+	for _, endpoint := range u.endpoints {
+		if endpoint.breaker.State() != gobreaker.StateOpen {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrUpstreamUnavailable, u.name)
+}
+
+func isUpstreamFailure(resp *http.Response) bool {
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// WithFailover sends the request to the first endpoint whose circuit breaker allows it,
+// and fails over to the next endpoint when the request errors or the upstream answers with 5xx or 429.
+func (u *upstream) WithFailover(rt http.RoundTripper) req.HttpRoundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host != u.endpoints[0].url.Host {
+			// redirects to other hosts, e.g. a custom default image, are not ours to fail over
+			return rt.RoundTrip(r)
+		}
+
+		span := trace.SpanFromContext(r.Context())
+
+		var (
+			lastResp *http.Response
+			lastErr  error
+		)
+		for i, endpoint := range u.endpoints {
+			state := endpoint.breaker.State()
+			done, err := endpoint.breaker.Allow()
+			if err != nil {
+				lastErr = fmt.Errorf("%s: %w", endpoint.name, err)
+				continue
+			}
+
+			if i > 0 {
+				span.AddEvent("upstream.failover", trace.WithAttributes(
+					attribute.String("upstream", u.name),
+					attribute.String("endpoint", endpoint.name),
+				))
+			}
+
+			resp, err := rt.RoundTrip(u.rewrite(r, endpoint))
+			if err != nil {
+				if r.Context().Err() != nil {
+					// a cancelled request says nothing about the upstream health, so it is counted neither way.
+					// gobreaker can't give back a half-open probe slot though, counting it as a failure keeps
+					// the breaker open for another timeout instead of closing it, or blocking the probes for good
+					if state == gobreaker.StateHalfOpen {
+						done(false)
+					}
+					return nil, err
+				}
+
+				done(false)
+				lastErr = err
+				continue
+			}
+
+			if isUpstreamFailure(resp) {
+				done(false)
+				if lastResp != nil {
+					_, _ = io.Copy(io.Discard, lastResp.Body)
+					_ = lastResp.Body.Close()
+				}
+				lastResp = resp
+				continue
+			}
+
+			done(true)
+			if lastResp != nil {
+				_, _ = io.Copy(io.Discard, lastResp.Body)
+				_ = lastResp.Body.Close()
+			}
+
+			return resp, nil
+		}
+
+		if lastResp != nil {
+			return lastResp, nil
+		}
+
+		return nil, fmt.Errorf("%w: %s: %v", ErrUpstreamUnavailable, u.name, lastErr)
+	}
+}
+
+type upstreamMetrics struct {
+	transitions *prometheus.CounterVec
+	state       *prometheus.Desc
+	upstreams   []*upstream
+}
+
+func newUpstreamMetrics(registry *prometheus.Registry) (*upstreamMetrics, error) {
+	m := &upstreamMetrics{
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "upstream_circuit_breaker_transitions_total",
+			Help: "Number of circuit breaker state transitions per upstream endpoint.",
+		}, []string{"upstream", "endpoint", "from", "to"}),
+		state: prometheus.NewDesc(
+			"upstream_circuit_breaker_state",
+			"Current circuit breaker state per upstream endpoint, 0 for closed, 1 for half-open and 2 for open.",
+			[]string{"upstream", "endpoint"},
+			nil,
+		),
+	}
+
+	if err := registry.Register(m.transitions); err != nil {
+		return nil, err
+	}
+	if err := registry.Register(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *upstreamMetrics) addUpstream(u *upstream) {
+	m.upstreams = append(m.upstreams, u)
+}
+
+func (m *upstreamMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.state
+}
+
+// Collect reads the breaker states at scrape time, as gobreaker only moves from open to half-open lazily.
+func (m *upstreamMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, u := range m.upstreams {
+		for _, endpoint := range u.endpoints {
+			ch <- prometheus.MustNewConstMetric(
+				m.state,
+				prometheus.GaugeValue,
+				float64(endpoint.breaker.State()),
+				u.name,
+				endpoint.name,
+			)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+	"github.com/AH-dark/gravatar-with-qq-avatar/pkg/utils"
+)
+
+var ErrResponseTooLarge = errors.New("upstream response body too large")
+
+// newUpstreamClient builds the http client of an upstream from its `upstreams.<section>` config,
+// dumping every request in debug mode.
+func newUpstreamClient(ctx context.Context, conf config.UpstreamConfig, debug bool, u *upstream) (*req.Client, error) {
+	ctx, span := tracer.Start(ctx, "service.AvatarService.newUpstreamClient")
+	defer span.End()
+
+	c := req.C().
+		SetBaseURL(u.endpoints[0].url.String()).
+		SetCommonHeader("Accept", conf.Accept).
+		SetCommonQueryParams(conf.Query).
+		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			if resp.Err != nil { // There is an underlying error, e.g. network error or unmarshal error (SetSuccessResult or SetErrorResult was invoked before).
+				if dump := resp.Dump(); dump != "" { // Append dump content to original underlying error to help troubleshoot.
+					resp.Err = fmt.Errorf("error: %s\nraw content:\n%s", resp.Err.Error(), resp.Dump())
+				}
+				return nil // Skip the following logic if there is an underlying error.
+			}
+
+			if !resp.IsSuccessState() {
+				resp.Err = fmt.Errorf("bad response, status: %s, raw content:\n%s", resp.Status, resp.Dump())
+			}
+
+			return nil
+		}).
+		WrapRoundTripFunc(WithTracer, u.WithTimeout)
+
+	if debug {
+		c.EnableDumpEachRequest()
+	}
+
+	dialer := &net.Dialer{Timeout: conf.DialTimeout}
+	c.SetDial(dialer.DialContext)
+
+	if proxy := conf.Proxy; proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("parse upstream proxy url failed", zap.String("upstream", u.name), zap.Error(err))
+			return nil, err
+		}
+
+		c.SetProxy(http.ProxyURL(proxyURL))
+	}
+
+	tlsConfig, err := utils.NewTLSConfig(ctx, utils.TLSParams{
+		CACertPath:         conf.TLS.CACert,
+		ClientCertPath:     conf.TLS.ClientCert,
+		ClientKeyPath:      conf.TLS.ClientKey,
+		InsecureSkipVerify: conf.TLS.InsecureSkipVerify,
+	})
+	if err != nil {
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("load upstream tls config failed", zap.String("upstream", u.name), zap.Error(err))
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tlsConfig.NextProtos = []string{"http/1.1", "h2"}
+		c.SetTLSClientConfig(tlsConfig)
+	}
+
+	t := c.GetTransport()
+	t.SetTLSHandshakeTimeout(conf.TLSHandshakeTimeout)
+	t.SetResponseHeaderTimeout(conf.ResponseHeaderTimeout)
+	t.SetMaxIdleConns(conf.Pool.MaxIdleConns)
+	t.MaxIdleConnsPerHost = conf.Pool.MaxIdleConnsPerHost
+	t.SetMaxConnsPerHost(conf.Pool.MaxConnsPerHost)
+	t.SetIdleConnTimeout(conf.Pool.IdleConnTimeout)
+	t.WrapRoundTripFunc(u.WithFailover, u.WithHedging, u.WithRetry, u.WithBodyLimit)
+
+	return c, nil
+}
+
+// WithTimeout bounds the request by the current timeout of the upstream, the body is read within it.
+func (u *upstream) WithTimeout(rt req.RoundTripper) req.RoundTripFunc {
+	return func(r *req.Request) (*req.Response, error) {
+		timeout := time.Duration(u.timeout.Load())
+		if timeout <= 0 {
+			return rt.RoundTrip(r)
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		return rt.RoundTrip(r.SetContext(ctx))
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	read  int64
+	limit int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, b.limit)
+	}
+
+	return n, err
+}
+
+// WithBodyLimit rejects responses whose body exceeds the configured max body size.
+func (u *upstream) WithBodyLimit(rt http.RoundTripper) req.HttpRoundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(r)
+		if err != nil || u.maxBodySize <= 0 {
+			return resp, err
+		}
+
+		if resp.ContentLength > u.maxBodySize {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("%w: content length %d exceeds %d bytes", ErrResponseTooLarge, resp.ContentLength, u.maxBodySize)
+		}
+
+		resp.Body = &limitedBody{ReadCloser: resp.Body, limit: u.maxBodySize}
+		return resp, nil
+	}
+}
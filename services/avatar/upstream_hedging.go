@@ -0,0 +1,226 @@
+package avatar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+type hedgePolicy struct {
+	enabled    bool
+	percentile float64
+	minSamples int
+	minDelay   time.Duration
+	maxDelay   time.Duration
+}
+
+func newHedgePolicy(conf config.HedgeConfig) hedgePolicy {
+	return hedgePolicy{
+		enabled:    conf.Enabled,
+		percentile: conf.Percentile,
+		minSamples: conf.MinSamples,
+		minDelay:   conf.MinDelay,
+		maxDelay:   conf.MaxDelay,
+	}
+}
+
+// latencyWindow keeps the latest upstream latencies in a ring buffer.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, size)}
+}
+
+func (w *latencyWindow) observe(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) == 0 {
+		return
+	}
+
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of the window and the number of samples it is based on.
+func (w *latencyWindow) percentile(p float64) (time.Duration, int) {
+	w.mu.Lock()
+	samples := w.samples[:w.next]
+	if w.full {
+		samples = w.samples
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	w.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0, 0
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(p*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+
+	return sorted[idx], len(sorted)
+}
+
+// hedgeDelay is the observed latency percentile clamped to [minDelay, maxDelay],
+// or maxDelay until the window holds enough samples.
+func (u *upstream) hedgeDelay() time.Duration {
+	delay, n := u.latencies.percentile(u.hedge.percentile)
+	if n < u.hedge.minSamples {
+		return u.hedge.maxDelay
+	}
+
+	if delay < u.hedge.minDelay {
+		delay = u.hedge.minDelay
+	}
+	if u.hedge.maxDelay > 0 && delay > u.hedge.maxDelay {
+		delay = u.hedge.maxDelay
+	}
+
+	return delay
+}
+
+type hedgeAttempt struct {
+	index  int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+func (a hedgeAttempt) ok() bool {
+	return a.err == nil && !isUpstreamFailure(a.resp)
+}
+
+func (a hedgeAttempt) discard() {
+	if a.resp != nil {
+		_, _ = io.Copy(io.Discard, a.resp.Body)
+		_ = a.resp.Body.Close()
+	}
+	a.cancel()
+}
+
+// cancelOnClose releases the context of the winning attempt once its body has been consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// WithHedging sends a second copy of an idempotent request when the first one hasn't answered
+// within the configured latency percentile, and returns whichever succeeds first.
+func (u *upstream) WithHedging(rt http.RoundTripper) req.HttpRoundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		if !isIdempotent(r) {
+			return rt.RoundTrip(r)
+		}
+
+		if !u.hedge.enabled {
+			start := time.Now()
+			resp, err := rt.RoundTrip(r)
+			if err == nil {
+				u.latencies.observe(time.Since(start))
+			}
+			return resp, err
+		}
+
+		results := make(chan hedgeAttempt, 2)
+		var cancels []context.CancelFunc
+		launch := func() {
+			ctx, cancel := context.WithCancel(r.Context())
+			index := len(cancels)
+			cancels = append(cancels, cancel)
+
+			go func() {
+				start := time.Now()
+				resp, err := rt.RoundTrip(r.Clone(ctx))
+				if err == nil {
+					u.latencies.observe(time.Since(start))
+				}
+				results <- hedgeAttempt{index: index, resp: resp, err: err, cancel: cancel}
+			}()
+		}
+
+		launch()
+		pending := 1
+
+		timer := time.NewTimer(u.hedgeDelay())
+		defer timer.Stop()
+
+		var failed *hedgeAttempt
+		for {
+			select {
+			case <-timer.C:
+				pending++
+				trace.SpanFromContext(r.Context()).AddEvent("upstream.hedge", trace.WithAttributes(
+					attribute.String("upstream", u.name),
+				))
+				launch()
+			case attempt := <-results:
+				pending--
+				if !attempt.ok() && pending > 0 {
+					// keep waiting for the other attempt, but remember this one in case it fails too
+					if failed != nil {
+						failed.discard()
+					}
+					failed = &attempt
+					continue
+				}
+
+				if failed != nil {
+					failed.discard()
+				}
+
+				// cancel the attempt still in flight and drop its response in the background
+				for i, cancel := range cancels {
+					if i != attempt.index {
+						cancel()
+					}
+				}
+				if pending > 0 {
+					go func(pending int) {
+						for ; pending > 0; pending-- {
+							(<-results).discard()
+						}
+					}(pending)
+				}
+
+				if attempt.resp != nil {
+					attempt.resp.Body = &cancelOnClose{ReadCloser: attempt.resp.Body, cancel: attempt.cancel}
+				} else {
+					attempt.cancel()
+				}
+
+				return attempt.resp, attempt.err
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package avatar
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	jitter         float64
+}
+
+func newRetryPolicy(conf config.RetryConfig) retryPolicy {
+	return retryPolicy{
+		maxAttempts:    conf.MaxAttempts,
+		initialBackoff: conf.InitialBackoff,
+		maxBackoff:     conf.MaxBackoff,
+		multiplier:     conf.Multiplier,
+		jitter:         conf.Jitter,
+	}
+}
+
+// backoff returns the capped exponential backoff before the given retry, starting from 1,
+// with a random part of `jitter` taken off to spread retries of concurrent requests.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	backoff := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(retry-1))
+	if p.maxBackoff > 0 && backoff > float64(p.maxBackoff) {
+		backoff = float64(p.maxBackoff)
+	}
+
+	return time.Duration(backoff * (1 - p.jitter*rand.Float64()))
+}
+
+func isIdempotent(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// retryAfter parses the delay-seconds form of the Retry-After header.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// WithRetry retries idempotent requests that failed with a network error, 5xx or 429.
+// It never sleeps past the deadline of the request context, the last result is returned instead.
+func (u *upstream) WithRetry(rt http.RoundTripper) req.HttpRoundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		if u.retry.maxAttempts <= 1 || !isIdempotent(r) {
+			return rt.RoundTrip(r)
+		}
+
+		ctx := r.Context()
+		for attempt := 1; ; attempt++ {
+			resp, err := rt.RoundTrip(r.Clone(ctx))
+			if ctx.Err() != nil || attempt >= u.retry.maxAttempts {
+				return resp, err
+			}
+			if err == nil && !isUpstreamFailure(resp) {
+				return resp, nil
+			}
+
+			delay := u.retry.backoff(attempt)
+			if d, ok := retryAfter(resp); ok && d > delay {
+				if u.retry.maxBackoff > 0 && d > u.retry.maxBackoff {
+					return resp, err
+				}
+				delay = d
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+				return resp, err
+			}
+
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+
+			trace.SpanFromContext(ctx).AddEvent("upstream.retry", trace.WithAttributes(
+				attribute.String("upstream", u.name),
+				attribute.Int("attempt", attempt+1),
+				attribute.String("backoff", delay.String()),
+			))
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
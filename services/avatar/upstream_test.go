@@ -0,0 +1,363 @@
+package avatar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+func newTestUpstream(t *testing.T, conf config.UpstreamConfig, name string, baseURL string) (*upstream, *req.Client) {
+	metrics, err := newUpstreamMetrics(prometheus.NewRegistry())
+	assert.NoError(t, err)
+
+	conf.BaseURL = baseURL
+	u, err := newUpstream(context.Background(), conf, metrics, name)
+	assert.NoError(t, err)
+
+	c, err := newUpstreamClient(context.Background(), conf, false, u)
+	assert.NoError(t, err)
+
+	return u, c
+}
+
+func TestUpstream_WithFailover(t *testing.T) {
+	asserts := assert.New(t)
+
+	var primaryHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer mirror.Close()
+
+	conf := config.UpstreamConfig{
+		Mirrors: []string{mirror.URL + "/mirror/"},
+		Breaker: config.BreakerConfig{ConsecutiveFailures: 2, Timeout: time.Minute},
+	}
+
+	u, c := newTestUpstream(t, conf, "test", primary.URL+"/avatar/")
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.R().Get("abc")
+		asserts.NoError(err)
+		asserts.Equal(http.StatusOK, resp.StatusCode)
+		asserts.Equal("/mirror/abc", resp.String())
+	}
+
+	// the breaker of the primary endpoint opened after two failures
+	asserts.EqualValues(2, primaryHits.Load())
+	asserts.Equal(gobreaker.StateOpen, u.endpoints[0].breaker.State())
+	asserts.Equal(gobreaker.StateClosed, u.endpoints[1].breaker.State())
+	// the mirror still takes requests
+	asserts.NoError(u.available())
+}
+
+func TestUpstream_WithFailoverCancelled(t *testing.T) {
+	asserts := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer svr.Close()
+
+	conf := config.UpstreamConfig{
+		// a mirror on the same host is told apart by its path
+		Mirrors: []string{svr.URL + "/mirror/"},
+		Breaker: config.BreakerConfig{ConsecutiveFailures: 1, Timeout: time.Minute},
+	}
+
+	u, c := newTestUpstream(t, conf, "test", svr.URL+"/avatar/")
+	asserts.Equal(svr.URL+"/avatar/", u.endpoints[0].name)
+	asserts.Equal(svr.URL+"/mirror/", u.endpoints[1].name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.R().SetContext(ctx).Get("abc")
+	asserts.Error(err)
+
+	// the cancelled request counts neither as a success nor as a failure
+	counts := u.endpoints[0].breaker.Counts()
+	asserts.Zero(counts.TotalSuccesses)
+	asserts.Zero(counts.TotalFailures)
+	asserts.Equal(gobreaker.StateClosed, u.endpoints[0].breaker.State())
+}
+
+func TestUpstream_WithFailoverAllUnavailable(t *testing.T) {
+	asserts := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	svr.Close()
+
+	conf := config.UpstreamConfig{
+		Breaker: config.BreakerConfig{ConsecutiveFailures: 1, Timeout: time.Minute},
+	}
+
+	u, c := newTestUpstream(t, conf, "test", svr.URL+"/")
+	asserts.NoError(u.available())
+
+	_, err := c.R().Get("abc")
+	asserts.Error(err)
+
+	_, err = c.R().Get("abc")
+	asserts.ErrorIs(err, ErrUpstreamUnavailable)
+	asserts.ErrorIs(u.available(), ErrUpstreamUnavailable)
+}
+
+func TestUpstream_WithRetry(t *testing.T) {
+	asserts := assert.New(t)
+
+	var hits atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer svr.Close()
+
+	conf := config.UpstreamConfig{
+		Retry: config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2},
+	}
+
+	_, c := newTestUpstream(t, conf, "test", svr.URL+"/")
+
+	resp, err := c.R().Get("abc")
+	asserts.NoError(err)
+	asserts.Equal("ok", resp.String())
+	asserts.EqualValues(3, hits.Load())
+}
+
+func TestUpstream_WithRetryRespectsDeadline(t *testing.T) {
+	asserts := assert.New(t)
+
+	var hits atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer svr.Close()
+
+	conf := config.UpstreamConfig{
+		Retry: config.RetryConfig{MaxAttempts: 5, InitialBackoff: time.Second, Multiplier: 2},
+	}
+
+	_, c := newTestUpstream(t, conf, "test", svr.URL+"/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	resp, _ := c.R().SetContext(ctx).Get("abc")
+	asserts.Equal(http.StatusInternalServerError, resp.GetStatusCode())
+	asserts.EqualValues(1, hits.Load())
+	asserts.Less(time.Since(start), 500*time.Millisecond)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	asserts := assert.New(t)
+
+	p := retryPolicy{
+		initialBackoff: 100 * time.Millisecond,
+		maxBackoff:     time.Second,
+		multiplier:     2,
+	}
+	asserts.Equal(100*time.Millisecond, p.backoff(1))
+	asserts.Equal(200*time.Millisecond, p.backoff(2))
+	asserts.Equal(400*time.Millisecond, p.backoff(3))
+	asserts.Equal(time.Second, p.backoff(10))
+
+	p.jitter = 0.5
+	for i := 0; i < 100; i++ {
+		asserts.GreaterOrEqual(p.backoff(1), 50*time.Millisecond)
+		asserts.LessOrEqual(p.backoff(1), 100*time.Millisecond)
+	}
+}
+
+func TestUpstream_WithHedging(t *testing.T) {
+	asserts := assert.New(t)
+
+	var hits atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			// the first request is stuck until the client gives up on it
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte("hedged"))
+	}))
+	defer svr.Close()
+
+	conf := config.UpstreamConfig{
+		Hedge: config.HedgeConfig{
+			Enabled:    true,
+			Percentile: 0.95,
+			Window:     10,
+			MinSamples: 5,
+			MaxDelay:   20 * time.Millisecond,
+		},
+	}
+
+	_, c := newTestUpstream(t, conf, "test", svr.URL+"/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := c.R().SetContext(ctx).Get("abc")
+	asserts.NoError(err)
+	asserts.Equal("hedged", resp.String())
+	asserts.EqualValues(2, hits.Load())
+}
+
+func TestUpstream_HedgeDelay(t *testing.T) {
+	asserts := assert.New(t)
+
+	u, _ := newTestUpstream(t, config.UpstreamConfig{
+		Hedge: config.HedgeConfig{
+			Enabled:    true,
+			Percentile: 0.5,
+			Window:     10,
+			MinSamples: 3,
+			MinDelay:   10 * time.Millisecond,
+			MaxDelay:   time.Second,
+		},
+	}, "test", "http://127.0.0.1/")
+
+	// a cold window hedges after max_delay rather than right away
+	asserts.Equal(time.Second, u.hedgeDelay())
+	u.latencies.observe(30 * time.Millisecond)
+	u.latencies.observe(40 * time.Millisecond)
+	asserts.Equal(time.Second, u.hedgeDelay())
+
+	u.latencies.observe(50 * time.Millisecond)
+	asserts.Equal(40*time.Millisecond, u.hedgeDelay())
+
+	for i := 0; i < 10; i++ {
+		u.latencies.observe(time.Millisecond)
+	}
+	asserts.Equal(10*time.Millisecond, u.hedgeDelay())
+}
+
+func TestLatencyWindow_Percentile(t *testing.T) {
+	asserts := assert.New(t)
+
+	w := newLatencyWindow(10)
+	_, n := w.percentile(0.5)
+	asserts.Equal(0, n)
+
+	for i := 1; i <= 20; i++ {
+		w.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	// only the latest 10 samples, 11ms to 20ms, are kept
+	p, n := w.percentile(0.5)
+	asserts.Equal(10, n)
+	asserts.Equal(15*time.Millisecond, p)
+
+	p, _ = w.percentile(1)
+	asserts.Equal(20*time.Millisecond, p)
+}
+
+func TestUpstream_WithBodyLimit(t *testing.T) {
+	asserts := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write(make([]byte, 2048))
+	}))
+	defer svr.Close()
+
+	conf := config.UpstreamConfig{MaxBodySize: 1024}
+
+	_, c := newTestUpstream(t, conf, "test", svr.URL+"/")
+
+	_, err := c.R().Get("abc")
+	asserts.ErrorIs(err, ErrResponseTooLarge)
+
+	_, err = c.R().SetQueryParam("chunked", "1").Get("abc")
+	asserts.ErrorIs(err, ErrResponseTooLarge)
+}
+
+func TestUpstream_WithTimeout(t *testing.T) {
+	asserts := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer svr.Close()
+
+	conf := config.UpstreamConfig{
+		Timeout: 50 * time.Millisecond,
+		Retry:   config.RetryConfig{MaxAttempts: 1},
+	}
+
+	u, c := newTestUpstream(t, conf, "test", svr.URL+"/")
+
+	_, err := c.R().Get("abc")
+	asserts.ErrorIs(err, context.DeadlineExceeded)
+
+	// the timeout is read per request, a config reload applies to the next one
+	u.timeout.Store(int64(time.Second))
+
+	resp, err := c.R().Get("abc")
+	asserts.NoError(err)
+	asserts.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestNewUpstreamClient(t *testing.T) {
+	asserts := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asserts.Equal("image/png", r.Header.Get("Accept"))
+		asserts.Equal("640", r.URL.Query().Get("spec"))
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer svr.Close()
+
+	conf := config.UpstreamConfig{
+		BaseURL: svr.URL + "/",
+		Accept:  "image/png",
+		Query:   map[string]string{"spec": "640"},
+		Proxy:   "://invalid",
+	}
+
+	metrics, err := newUpstreamMetrics(prometheus.NewRegistry())
+	asserts.NoError(err)
+
+	u, err := newUpstream(context.Background(), conf, metrics, "test")
+	asserts.NoError(err)
+
+	_, err = newUpstreamClient(context.Background(), conf, false, u)
+	asserts.Error(err)
+
+	conf.Proxy = ""
+	c, err := newUpstreamClient(context.Background(), conf, false, u)
+	asserts.NoError(err)
+
+	resp, err := c.R().Get("abc")
+	asserts.NoError(err)
+	asserts.Equal("ok", resp.String())
+}
@@ -0,0 +1,79 @@
+package cdn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/imroc/req/v3"
+	"github.com/samber/lo"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+type httpPurgeRequest struct {
+	SurrogateKeys []string `json:"surrogate_keys"`
+}
+
+// httpPurger sends the surrogate keys to a purge endpoint, both in the `Surrogate-Key` header
+// and as a json body, which covers most CDN purge APIs and self-hosted caches like varnish.
+type httpPurger struct {
+	client    *req.Client
+	method    string
+	url       string
+	batchSize int
+}
+
+func newHTTPPurger(ctx context.Context, conf config.HTTPPurgeConfig) (*httpPurger, error) {
+	ctx, span := tracer.Start(ctx, "service.CDN.newHTTPPurger")
+	defer span.End()
+
+	p := &httpPurger{
+		client: req.C().
+			SetCommonHeaders(conf.Headers).
+			SetTimeout(conf.Timeout),
+		method:    strings.ToUpper(conf.Method),
+		url:       conf.URL,
+		batchSize: conf.BatchSize,
+	}
+
+	if p.url == "" {
+		err := errors.New("cdn.purge.http.url is required")
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("create http purger failed", zap.Error(err))
+		return nil, err
+	}
+	if p.batchSize <= 0 {
+		p.batchSize = 1
+	}
+
+	return p, nil
+}
+
+func (p *httpPurger) Purge(ctx context.Context, keys ...string) error {
+	ctx, span := tracer.Start(ctx, "service.CDN.httpPurger.Purge")
+	defer span.End()
+
+	for _, batch := range lo.Chunk(keys, p.batchSize) {
+		resp, err := p.client.R().
+			SetContext(ctx).
+			SetHeader("Surrogate-Key", strings.Join(batch, " ")).
+			SetBodyJsonMarshal(httpPurgeRequest{SurrogateKeys: batch}).
+			Send(p.method, p.url)
+		if err != nil {
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("purge cdn failed", zap.Strings("keys", batch), zap.Error(err))
+			return err
+		} else if resp.IsErrorState() {
+			err := fmt.Errorf("purge cdn failed, status: %s", resp.Status)
+			span.RecordError(err)
+			otelzap.L().Ctx(ctx).Error("purge cdn failed", zap.Strings("keys", batch), zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+func TestHTTPPurger_Purge(t *testing.T) {
+	asserts := assert.New(t)
+
+	var (
+		mu       sync.Mutex
+		received [][]string
+	)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asserts.Equal(http.MethodPost, r.Method)
+		asserts.Equal("Bearer token", r.Header.Get("Authorization"))
+
+		var body httpPurgeRequest
+		asserts.NoError(json.NewDecoder(r.Body).Decode(&body))
+
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, body.SurrogateKeys)
+
+		if r.Header.Get("Surrogate-Key") == "avatar-fail" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer svr.Close()
+
+	conf := &config.Config{}
+	conf.CDN.Purge.Type = "http"
+	conf.CDN.Purge.HTTP = config.HTTPPurgeConfig{
+		URL:       svr.URL,
+		Method:    "post",
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		BatchSize: 2,
+	}
+
+	p, err := NewPurger(context.Background(), conf)
+	asserts.NoError(err)
+
+	asserts.NoError(p.Purge(context.Background(), SurrogateKey("a"), SurrogateKey("b"), SurrogateKey("c")))
+	asserts.Equal([][]string{{"avatar-a", "avatar-b"}, {"avatar-c"}}, received)
+
+	asserts.Error(p.Purge(context.Background(), SurrogateKey("fail")))
+}
+
+func TestNewPurger(t *testing.T) {
+	asserts := assert.New(t)
+
+	conf := &config.Config{}
+	p, err := NewPurger(context.Background(), conf)
+	asserts.NoError(err)
+	asserts.NoError(p.Purge(context.Background(), "avatar-a"))
+
+	conf.CDN.Purge.Type = "http"
+	_, err = NewPurger(context.Background(), conf)
+	asserts.Error(err)
+
+	conf.CDN.Purge.Type = "unknown"
+	_, err = NewPurger(context.Background(), conf)
+	asserts.Error(err)
+}
@@ -0,0 +1,49 @@
+// Package cdn integrates with the CDN in front of the avatar routes.
+package cdn
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"github.com/AH-dark/gravatar-with-qq-avatar/common/config"
+)
+
+var tracer = otel.Tracer("github.com/AH-dark/gravatar-with-qq-avatar/services/cdn")
+
+// SurrogateKey tags every response of an email hash, whatever its size or format,
+// so all of them can be purged at once.
+func SurrogateKey(hash string) string {
+	return "avatar-" + hash
+}
+
+// Purger evicts the responses tagged with the surrogate keys from the CDN.
+type Purger interface {
+	Purge(ctx context.Context, keys ...string) error
+}
+
+func NewPurger(ctx context.Context, conf *config.Config) (Purger, error) {
+	ctx, span := tracer.Start(ctx, "service.CDN.NewPurger")
+	defer span.End()
+
+	switch conf.CDN.Purge.Type {
+	case "", "none":
+		return noopPurger{}, nil
+	case "http":
+		return newHTTPPurger(ctx, conf.CDN.Purge.HTTP)
+	default:
+		err := fmt.Errorf("cdn purge type %s not supported", conf.CDN.Purge.Type)
+		span.RecordError(err)
+		otelzap.L().Ctx(ctx).Error("create cdn purger failed", zap.Error(err))
+		return nil, err
+	}
+}
+
+type noopPurger struct{}
+
+func (noopPurger) Purge(context.Context, ...string) error {
+	return nil
+}